@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypoolCheck/config"
+)
+
+// adminOnly guards the api changing things. With admin_token set the request must carry it as a bearer token,
+// without it only localhost is let in. The peer address is used, never X-Forwarded-For
+func adminOnly(c *gin.Context) {
+	token := config.Get().AdminToken
+	if token == "" {
+		if !isLoopback(c.Request.RemoteAddr) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only localhost can use this api, set admin_token to use it from other hosts"})
+		}
+		return
+	}
+	got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "bad or missing admin token"})
+	}
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -132,7 +132,7 @@ func assetsHtmlClashHtml() (*asset, error) {
 	return a, nil
 }
 
-var _assetsHtmlIndexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x57\xdd\x53\x1b\xc9\x11\x7f\xa7\x8a\xff\x61\x6e\x1f\x72\x97\x8b\xc4\x1e\xf6\x4b\x4a\xb5\xbb\x97\x14\xe7\xcb\xe5\xf2\x10\x2a\xe0\x3a\xe7\x89\x5a\xed\x0e\xda\x09\xab\xdd\xf5\xcc\x2c\xa0\x50\x54\x49\x36\x32\x1f\x06\x84\x6d\x8c\x13\x90\x8d\x21\xb6\xa3\x72\xf8\x72\xe1\x22\x02\x84\xf9\x5f\xc2\x7e\x48\x4f\xfc\x0b\xa9\xd9\x95\x8c\x3e\x10\x06\xe3\x27\x69\x7a\x7e\xfd\xeb\xe9\x9e\xee\xe9\x5e\xe1\xab\x1f\xfe\xdc\xd3\xff\xd7\xde\x5b\x40\xa3\x49\x5d\xea\xec\x10\xd8\x2f\xd0\x65\x23\x21\x72\xd0\xe0\xa4\xce\x0e\x26\x83\xb2\x2a\x75\x76\x00\x00\x80\x90\x84\x54\x06\x8a\x26\x63\x02\xa9\xc8\xd9\x74\x30\xfa\x5b\xae\x61\x4f\xa3\xd4\x8a\xc2\xbb\x36\x1a\x16\xb9\x3b\xd1\xdb\xbf\x8f\xf6\x98\x49\x4b\xa6\x28\xae\x43\x0e\x28\xa6\x41\xa1\x41\x45\xee\x8f\xb7\x44\xa8\x26\x60\xa3\xaa\x21\x27\xa1\xc8\xfd\x24\x1b\xaa\x06\x75\xf5\x47\x8c\xa0\xa1\xea\xa9\x3a\xad\x7e\x6c\x9f\xa9\x50\x44\x75\x28\xfd\x88\x21\x04\xbd\xd8\x1c\x45\x90\x08\x7c\x28\xab\xe7\xb4\xb0\x69\x41\x4c\x53\x22\x67\x26\x62\x04\x51\x38\xc0\xac\xd4\x71\xd6\x13\x70\xed\x55\x55\x48\x14\x8c\x2c\x8a\x4c\xa3\x4e\xd9\xcd\xce\x95\xdf\xef\xfb\xcb\x13\x84\x9c\xa4\x33\x84\xe0\x93\x74\x66\x38\x09\x83\x15\xc5\xe6\xdf\x64\xa3\x3c\x73\xcf\xbf\xb7\x7f\x9e\x9f\x43\x30\x35\x62\x62\x95\x5c\x96\xce\xc4\x17\xd2\x0d\x23\x38\x62\x99\x98\xd6\xd1\x8d\x20\x95\x6a\xa2\x0a\x87\x91\x02\xa3\xc1\x22\x02\x90\x81\x28\x92\xf5\x28\x51\x64\x1d\x8a\xdd\x5d\xdf\x45\x40\x52\x1e\x45\x49\x3b\x59\x2f\xb2\x09\xc4\xc1\x5a\x8e\xeb\x50\xfc\x8e\x03\x7c\xcd\xe2\x57\xd1\x28\xe8\xd7\x10\x01\x3a\x32\x20\x40\x04\x0c\x9a\x18\xe8\xa6\x22\xeb\x80\x42\x42\xa3\xd1\x7a\xa0\xa0\x23\x63\x08\x60\xa8\x8b\x1c\xa1\x29\x1d\x12\x0d\x42\xca\x01\x9a\xb2\xa0\xc8\x51\x38\x4a\x79\x85\x10\x0e\x68\x18\x0e\x8a\x5c\x57\x17\x5b\xf1\xc8\x50\xe1\x68\x17\x93\x4b\xe0\x8c\xed\x2a\x44\xcd\x34\x9d\x1d\x02\x5f\x4d\xe1\xce\x0e\x21\x6e\xaa\xa9\x1a\xab\x8a\x86\x81\xa2\xcb\x84\x88\x1c\x35\xad\xb8\x8c\x6b\x81\x0d\x76\xb5\x6e\x49\x20\x14\x9b\x46\xa2\x29\xcb\xaa\x42\x81\xd7\xba\xeb\xf1\x56\x8d\x2b\xcc\x15\x4e\x2a\x4f\xbe\x75\x67\x0a\xde\xcc\x13\x37\xb7\x44\x13\x95\xf5\x47\x95\xcc\x93\x93\x74\xa6\xbc\xb5\x5e\xf9\x47\xd6\xcd\xef\xb8\xcf\xd3\x27\xe9\x8c\x9b\xdd\x70\x4b\x69\xe7\xe0\x71\x39\xb3\xe8\x1f\x3d\x72\x8a\x33\x9f\xce\x27\xe7\x78\xcd\xcb\x6c\x9f\x96\x66\xcb\x99\x65\x77\x61\xca\x9d\x3f\xac\x4c\xce\xb9\x0b\xf3\x5e\x6e\xc1\xf9\xb0\x12\x62\xdc\xa9\x67\xe5\xb5\x82\xc0\x5b\x35\x6f\x79\x15\x0d\xb7\x7a\xfe\x35\x81\x0a\xcb\xea\xaf\x1b\x5c\xbf\x21\xf9\x87\xab\xe5\xad\x35\x81\xd7\x6e\x34\xf8\x28\x79\xf9\x39\x77\x66\xcd\xff\xcf\xb6\x9b\x7d\xe7\xe5\xa7\xdd\x6c\xa1\x72\xbf\x10\x5a\x14\xe2\x12\x18\x1b\x03\x5d\xb2\xae\x0f\x58\x61\xb0\x06\x14\xd3\x36\x28\x18\x1f\x07\x02\x1f\x97\x9c\xe2\xdb\x93\xf4\x3d\x37\xbb\xe7\x14\x37\xdd\xdc\xb6\xbf\x58\x20\xa4\x51\x95\x90\xb6\x9a\xa7\xa5\x59\x42\x70\x33\x1c\x5f\x84\x0f\x42\xd7\xa8\x11\x88\x2e\xd2\xa9\x0f\x73\x4d\x29\x94\xb5\xd5\x3a\x8b\x71\x35\x46\xa1\x6f\x21\x87\xf7\x74\xa7\x32\x99\x3b\x2d\x2d\x0b\x71\x89\x71\xd9\x04\x0e\xda\xad\xf1\x61\x54\x2d\x3c\x5e\x3e\xcd\x2e\x75\xe5\xbd\xb7\xb4\xe3\x3d\xdb\xab\x3c\x7b\x7f\xc6\xa3\xcb\x84\x0e\x28\x58\x1e\xd1\x07\x28\x4a\xc2\x16\x8a\x36\xd7\xcd\x55\xaf\x9b\x6b\xba\x6e\x41\xae\x01\x12\x18\x42\xe3\xac\x96\x74\x99\x68\x9c\xd4\xc3\x7e\x04\x5e\x96\x9a\x13\xc2\xd6\xeb\x56\xd5\x52\x0d\xd1\x95\xec\x9c\x7f\xb4\xe5\x2d\x4d\x3a\x87\x7b\xb1\x20\x8c\x18\xde\xb5\x21\x61\xde\xc6\x78\x9e\x09\x54\x33\x29\x23\x83\xad\xd9\x8a\xbd\x5e\x60\x7c\x3c\x34\xc9\x2b\xa6\x31\x88\x12\xa0\xcd\xb9\x02\x4c\x8c\xe7\x91\x41\xa8\xac\xeb\xd1\x10\xfd\xbd\x8d\x75\xf1\x33\x2d\x71\x92\x53\x4c\x57\x16\xb7\xdc\xed\x92\x9b\x7d\x1d\xba\xaa\xa3\x76\xce\xb1\xe6\x30\x9a\x8a\x5a\xd8\x1c\x46\x2a\xc4\xa7\xa5\xe5\x2b\x9b\xb5\x6a\xcf\xc9\xb9\x56\xfc\xcd\x95\x4a\x7a\xda\x39\xfc\x97\xbf\xf0\xc0\x7f\x77\xe8\xbe\x78\x78\x0d\x1b\xdf\x07\x0f\x25\x21\x11\x42\x70\x24\xa8\x80\x0b\xad\xba\x2b\x47\xee\xd6\xde\x75\xec\x29\xe2\x4f\x7f\x8a\xf4\xff\x12\xb9\xdd\xf7\x2b\x43\x11\x7f\xee\x05\xdf\x28\x4e\xf1\xa0\x92\x4f\x97\xdf\x64\xfc\xe5\x89\x9a\x81\x59\x83\x89\x9d\xe2\x5c\xd3\xce\xaf\x2f\x0e\x4a\x71\xb3\x7c\xb4\x71\x9d\xe3\x0d\x22\x9d\x42\x2c\x62\xf0\x0d\x0e\xec\x87\x7c\xb3\x96\x53\x3c\xb0\x4c\x53\x3f\x2d\xcd\x62\xeb\xe3\xc6\x6f\xaa\x22\x83\x61\x2b\xcf\x5f\x7c\x84\x1b\x56\x28\xf8\xb8\xcf\xd6\xee\xfc\xaa\xbf\xb8\x8a\xac\x76\x2e\x54\x8b\x79\x69\xdf\xdb\x7d\x7a\x5a\x5a\x6e\x04\x54\x2b\xaa\x96\xf1\xac\x87\x19\x94\x93\x5a\x41\x8d\xb9\xc8\x5a\xf0\x2f\xc8\x50\xcd\x11\x12\x03\xa1\x81\xfa\xe2\x73\xe7\x76\xdd\xdc\xf6\x39\xe7\x69\x61\xba\x13\x03\x3f\xc8\x44\x8b\x9b\x32\x56\x9d\xe2\xe6\x69\x69\x96\xf5\x91\xc9\x43\x6f\x3b\xe7\x14\xdf\xfa\x9b\x4b\xfe\xd3\xd7\xfe\xe1\x84\x9b\xdb\x75\x8a\x33\xe5\x7f\x3f\x66\x17\x36\xf5\x5f\x6f\x69\xc7\x5d\xf9\xe0\xbd\x9c\xbc\x94\x89\x5e\x6c\xc6\x40\x4f\x58\xdc\xff\x7b\xf0\x18\xdc\xb6\x54\x99\x42\x70\x6b\x94\x42\x6c\xc8\x3a\xe8\x33\x6d\xac\x9c\x5b\x16\x01\x0f\xdf\xfa\xe0\x34\x22\xeb\x10\x57\x7c\x02\xfb\x34\x59\x35\x47\xfe\x62\x2a\x43\x90\x5e\xf8\xcc\x31\x67\xaa\x7d\x2f\x68\xe5\x5f\xae\xfa\x03\xe6\x62\xd1\x5f\x2c\x7c\xc9\x1a\x8c\xfc\xdc\x1b\xe9\xfb\xc3\x17\x8b\x53\xbb\x56\x41\x6c\xcc\x26\xfa\x3e\xf6\x73\xe9\x56\x11\xa0\xeb\xb3\xf5\x6a\x2e\x07\x36\x3f\xd1\x2b\x02\xcc\xcd\x18\x7f\xdd\x6e\x51\x6f\xeb\xd2\xdd\x22\xf0\x2f\xec\x16\x40\x47\x84\x7e\x8e\x7b\x6d\x72\xa5\xf5\x02\x2f\x75\x85\x63\x63\x51\xc0\x7f\x0b\xbc\xe9\xb4\x97\x9f\x76\x8e\x8e\xfd\xc5\x82\x97\xdf\x60\x9d\xe6\x65\x26\x9c\x1f\xab\x23\xde\xf2\x84\xff\x26\xe3\x6d\xae\xbb\x53\x2f\x2b\xff\x7c\x75\x92\xce\x38\xc7\x5b\xde\xe2\xbe\x3f\x3d\xe5\x3d\xbf\x1f\x4e\xa0\xdf\xf2\xe3\xe3\x8d\x13\xcb\xc6\x7a\xf9\x78\xde\xcd\xee\x7a\xbb\x05\x77\x7e\xd5\x39\xca\x97\xd3\xd9\xda\xec\xcb\x86\x17\xb9\x7a\x29\xec\x33\x91\xc4\x78\x9e\x76\x25\x21\x6f\x41\x38\x34\x68\x1b\x9c\xf4\xbb\xea\xbf\x30\xa0\xcd\x53\x55\x8d\xef\x3c\x9e\x04\xa2\x9a\x1d\xef\x52\xcc\x24\xff\x77\xbb\x7b\x28\x88\x59\x8a\xbd\xcc\x51\x9c\xe4\xa4\xcb\xa0\xce\xb5\xe9\x6d\xbe\xf2\x0b\x0f\xdd\x83\x1c\xf3\x3a\xbf\xf1\x09\xcb\x44\x36\x88\x8d\x6e\xdc\xbc\x79\x46\xac\x68\x50\x19\x3a\xf7\x00\xed\xc0\xec\x1c\xe1\xa8\x0a\x31\x41\xa6\x11\x4c\x76\xcd\x53\x9d\xc0\x57\xbf\x63\x82\x8f\x1b\xf6\xed\xfe\xff\x00\x00\x00\xff\xff\x1f\x9e\xb3\xb3\xcb\x0f\x00\x00")
+var _assetsHtmlIndexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x57\x5b\x73\xdb\xc6\x15\x7e\xd7\xaf\xd8\xe0\xa1\x71\x53\x52\x88\xe5\x97\x0e\x07\x40\xda\x71\x9c\xa6\xe9\x74\xaa\xa9\xec\x49\xfa\xa4\x01\x81\x95\xb0\xd5\x12\x40\x76\x17\x92\x58\x8f\x66\x48\xc7\xb4\x2e\xd6\x2d\x8e\x2c\xb7\x12\x7d\x91\x9b\xa4\x1c\x57\x17\x67\xe4\x71\x29\x8b\x8a\xfe\x4b\x85\x05\xc8\x27\xfd\x85\xcc\x02\x10\x05\xea\xae\x28\x23\xcd\x80\x7b\xf6\x9c\xef\xdb\xb3\x7b\xce\x9e\xb3\xca\x7b\x1f\xff\xe5\xe6\xed\xbf\xf5\xde\x02\x16\x2b\x60\xad\x4b\x11\x1f\x80\x75\x7b\x50\x95\xa0\x2d\x69\x5d\x5d\x8a\x05\x75\x53\xeb\x02\x00\x00\xa5\x00\x99\x0e\x0c\x4b\x27\x14\x32\x55\xf2\xd8\x40\xf6\xb7\x52\x7a\xca\x62\xcc\xcd\xc2\x2f\x3d\x34\xac\x4a\x5f\x64\xef\xfc\x3e\x7b\xd3\x29\xb8\x3a\x43\x79\x0c\x25\x60\x38\x36\x83\x36\x53\xa5\x3f\xde\x52\xa1\x39\x08\x3b\x2c\x6d\xbd\x00\x55\xe9\x53\xdd\x36\x2d\x88\xcd\x4f\x08\x82\xb6\x89\x8b\x29\xa3\xdb\xc4\x6b\x5b\x30\xc4\x30\xd4\x3e\x21\x10\x82\x5e\xe2\x8c\x22\x48\x15\x39\x96\xa5\x10\x5d\xe2\xb8\x90\xb0\xa2\x2a\x39\x83\x39\x8a\x18\xec\x17\x1c\x29\xc4\xb4\xbd\x74\xaa\xa5\x09\xa9\x41\x90\xcb\x90\x63\xa7\x6c\x79\x65\xa6\xf9\x66\x2b\x5c\xba\x4f\xe9\x5e\xa9\x4c\x29\xd9\x2b\x95\x87\x0b\x30\x1a\x31\xe2\xfc\x5d\xb7\x9b\x53\xf7\xc2\x7b\x5b\x1d\xb8\x82\x5f\x95\x86\x60\x71\xc4\x21\x26\xbd\x28\x9a\x43\xce\x42\x1b\x46\x70\xc4\x75\x08\x4b\xa1\x8d\x20\x93\x59\xaa\x09\x87\x91\x01\xb3\xd1\x20\x03\x90\x8d\x18\xd2\x71\x96\x1a\x3a\x86\xea\xf5\xee\x0f\x33\xa0\xa0\x8f\xa2\x82\x57\x48\x8b\x3c\x0a\x49\x34\xd6\xf3\x18\xaa\x1f\x4a\x40\x4e\x08\xdf\xcb\x66\xc1\x6d\x0b\x51\x80\x91\x0d\x01\xa2\x60\xc0\x21\x00\x3b\x86\x8e\x01\x83\x94\x65\xb3\x29\x3d\x05\x23\x7b\x08\x10\x88\x55\x89\xb2\x22\x86\xd4\x82\x90\x49\x80\x15\x5d\xa8\x4a\x0c\x8e\x32\xd9\xa0\x54\x02\x16\x81\x03\xaa\xd4\xdd\x2d\x46\x32\xb2\x4d\x38\xda\x2d\xe4\x1a\x68\x83\x5d\x06\xe7\x28\x4a\x97\x22\xc7\x61\xdb\xa5\xe4\x1d\xb3\x98\x20\x9a\x68\x18\x18\x58\xa7\x54\x95\x98\xe3\xe6\x75\x92\x6c\xa8\xf8\x57\xac\xeb\x9a\x42\x19\x71\xec\xc1\x23\x91\x95\x08\x15\xd9\xba\x9e\x52\x77\x0f\x90\xe2\x00\x91\xb4\xe6\xf8\x2b\x3e\x55\x0b\xa6\xbe\xe1\x73\x8b\x6c\xb0\xf5\xf2\xeb\x56\xf9\x9b\xbd\x52\xb9\xb9\xfe\xb2\xf5\xcf\x0a\xaf\xbe\xe6\x4f\x4b\x7b\xa5\x32\xaf\xac\xf2\x46\xc9\x7f\xf7\xa8\x59\x5e\x08\x77\xbe\xf6\xeb\x53\xe7\x07\x91\xbf\xbb\x12\x94\x37\xf6\x1b\xd3\xcd\xf2\x12\x9f\x9f\xe0\xb3\xdb\xad\xf1\x19\x3e\x3f\x1b\xcc\xcd\xfb\x3f\x2e\xc7\x3a\x7c\xe2\x49\x73\xa5\xa6\xc8\x6e\xe2\xaa\x6c\xa2\xe1\x63\x5e\xbf\x4f\xa1\x21\x02\xf9\xfd\xb4\xdb\x3d\x5a\xb8\xfd\xbc\xb9\xbe\xa2\xc8\x56\x4f\xda\x3f\x2d\xa8\xce\xf0\xa9\x95\xf0\xbf\x1b\xbc\xf2\x43\x50\x9d\xe4\x95\x5a\xeb\xab\x5a\xcc\xa6\xe4\x35\x70\xf7\x2e\xe8\xd6\x31\xee\x77\xe3\x0c\xea\x37\x1c\xcf\x66\x60\x6c\x0c\x28\x72\x5e\xf3\xeb\xaf\xf6\x4a\xf7\x78\xe5\xad\x5f\x5f\xe3\x73\x1b\xe1\x42\x8d\xd2\x4e\x53\x4a\x4f\xb5\xdc\x6f\x4c\x53\x4a\x8e\xaa\x93\xb3\xf4\xa3\x6d\xeb\xb4\x88\x44\x67\xd9\xa4\xb7\xf8\xc0\x28\x96\x9d\xc9\x84\x8f\x33\xe1\x73\x98\xac\x22\x65\x90\x20\xbd\xa7\xd3\xae\x2d\x3e\xcb\x96\x79\xc8\xe8\x34\x13\x92\x53\x2d\xda\x11\x90\x1c\x62\xbc\xf9\x31\x40\xf0\xf8\x75\x6b\x7c\x6e\xbf\xb1\xa4\xe4\x35\x01\xe4\x51\x38\xe0\x1d\x3f\x40\x81\x74\x14\x26\xa8\x96\x44\xc0\x2d\xbf\x09\x16\x5f\x07\x4f\xde\xb6\x9e\xbc\x39\x84\xc1\x3a\x65\xfd\x06\xd1\x47\x70\x3f\x43\x05\x78\x0a\x82\x5f\x7f\x18\xac\xae\x34\x77\xe7\x9b\x2b\xd3\x47\x11\x6c\x38\xca\xfa\x89\x67\x9f\x68\x7f\x72\x28\x4b\x49\x28\x77\x64\x70\x8f\xa6\xe8\x07\xf3\x83\x04\x42\xfb\xf0\x7a\xc0\x3a\xb5\x24\xed\xa6\xf8\x28\xb2\xae\x1d\x09\x76\x0f\x1f\x0e\x92\xbb\x27\xd6\x6d\x55\x66\xc2\x9d\xf5\x60\x71\xdc\xdf\x7e\x9b\x8b\x0e\x9b\xc0\x2f\x3d\x48\xc5\x3e\xe5\x64\x59\x08\x4c\xa7\xa0\x23\x5b\x8c\xc5\x48\xdc\xc5\x60\x6c\x2c\x26\x94\x0d\xc7\x1e\x40\x83\xe0\x94\x55\x45\x3a\x39\x59\x46\x36\x65\x3a\xc6\xd9\x58\xfb\x23\x8f\x60\xf5\x67\x32\x49\x9a\x5f\x2f\xb5\x16\xd6\xf9\x46\x83\x57\xbe\x8b\x1d\xc5\xe8\x14\xdf\x44\x9d\x1b\x2d\x66\x5d\xe2\x0c\x23\x13\x92\xfd\xc6\xd2\xa5\x59\x93\xd0\x39\x99\x24\x5c\x5b\x6e\x95\x26\xfd\xed\x7f\x87\xf3\x0f\xc2\x1f\xb6\xf9\xb3\x87\x57\xa0\xf8\x28\xba\xf7\x29\xcd\x50\x4a\x32\x51\x6a\x67\xe2\x5c\xcd\x44\xd9\x97\x69\xe7\x52\x46\xa4\x07\xb8\x16\x49\xf7\x4a\xe5\xb6\x5c\x94\x52\x0f\x19\xad\x6a\xa9\xf9\x7d\x39\xf2\xbf\xfb\xcf\x90\xe9\xfc\x41\x25\x78\x51\xff\xf5\x59\x1e\xf0\xe5\x1d\xbe\xfe\xf6\x2a\x6b\x37\xd4\x4f\xff\x94\xb9\xfd\x79\xe6\x4e\xdf\xaf\x6c\x43\xfd\xac\x17\x5c\x33\xfc\xfa\xbb\x78\x29\xe1\xd2\xfd\x03\x82\x69\x5b\x88\xfd\xfa\xcc\x91\x99\x33\x57\xe7\xd7\xd7\x9a\x3b\xab\x57\x59\xdd\x00\xc2\x0c\x12\x95\x80\x6b\x24\xa2\x8f\xf1\xa6\x5d\xbf\xfe\xce\x75\x1c\xbc\xdf\x98\x26\x6e\x7b\xe2\x37\x89\xc8\x16\xba\xad\xa7\xcf\xda\xea\xb6\xd0\x69\x3d\x7d\xd6\x9e\x17\x63\x3e\xfb\x3c\x5c\x78\x8e\xdc\x53\x3c\x48\xee\x93\xc5\xad\x60\xf3\xf1\x7e\x63\xa9\x63\x3e\xc9\xcb\x83\xc4\x11\x1d\x82\xcd\x24\xed\x98\xce\x01\x56\x74\xa4\x51\x5f\xf2\x39\xb2\x4d\x67\x84\xe6\x40\x0c\x9f\x4e\x61\x3e\xb3\xc9\xe7\x36\x8e\x2f\xe6\x18\xd0\x17\x39\xf0\xb1\x4e\xad\xbc\xa3\x13\xd3\xaf\xaf\xed\x37\xa6\xc3\x7b\x5b\x7c\x7c\x3b\xd8\x98\xf3\xeb\xaf\xc2\xb5\xc5\xf0\xf1\x77\xe1\xf6\x7d\x3e\xb7\xe9\xd7\xa7\x9a\xff\x79\x24\xce\x6a\xe2\x7f\xc1\xe2\x6b\xbe\xfc\x63\xf0\x62\xfc\x22\x0c\xbd\xc4\xc9\x81\x9b\x51\xda\x82\xff\x3f\x78\x04\xee\xb8\xa6\xce\x20\xb8\x35\xca\x20\xb1\x75\x0c\xfa\x1c\x8f\x18\x27\xe5\x96\xf8\x53\xe4\x63\x57\x56\x87\xde\xe1\xfc\xa5\xae\xcf\x3e\x4b\x37\x9d\x91\xbf\x3a\xc6\x10\x64\x67\x5c\x91\xc2\x89\xa4\x1d\x88\xba\x9b\x2b\x44\x5f\xa7\x7b\x11\x70\xbd\x1e\x2e\xd4\x7e\xc9\xa4\xcb\x7c\xd6\x9b\xe9\xfb\xc3\x2f\xb2\x41\xa7\xd5\x17\xea\x11\xf1\xa0\xe9\x13\x9f\x0b\xd6\x97\x48\x37\x1d\x9c\x97\x73\x36\x62\x3c\xa7\xc0\x44\x3a\x37\x72\xf2\x55\x4b\x4c\x9a\xeb\xa2\x25\x26\x72\x2f\x2e\x31\x00\x23\xca\x7e\x8e\x77\x27\xc7\xc8\xd1\x93\xbb\xc0\xd1\xdd\xbd\x9b\x05\xf2\x07\x20\x98\x2c\x05\xd5\x49\x7f\x67\x37\x5c\xa8\x05\xd5\x55\x51\x9a\x5e\x94\xe3\x2e\x3a\x69\x76\x97\xee\x87\xdf\x97\x83\xb5\x97\x7c\xe2\x45\xeb\x5f\xdf\xee\x95\xca\xfe\xee\x7a\xb0\xb0\x15\x4e\x4e\x04\x4f\xbf\x8a\xfb\xf0\x0f\xe4\xb1\xb1\xc3\xc5\xb8\x5a\xb0\xfa\xb2\xb9\x3b\xcb\x2b\x9b\xc1\x66\x8d\xcf\x3e\xf7\x77\xaa\xcd\x52\xe5\xe0\x01\x20\x7a\x1c\x3d\x39\x0d\xf1\x38\xa6\x39\x59\x66\xdd\x05\x28\xbb\x10\x0e\x0d\x78\xb6\xa4\xfd\x2e\xf9\x15\xef\x64\x67\xcf\xd4\x86\x3b\x09\x66\x10\x31\xcb\xcb\x77\x1b\x4e\x41\xfe\x87\x77\x7d\x28\xda\xac\xa2\xb8\x7d\xb3\xa4\x20\x69\x17\xd1\x3a\x89\x32\x58\xfb\x36\xac\x3d\xe4\xef\xe6\x84\xcb\xd5\xd5\x73\x88\xa9\x6e\x53\x0f\xf5\xdc\xb8\x71\x88\x6b\x58\xd0\x18\x92\xb4\x4b\x28\x8b\x65\x44\xad\xd5\x30\x24\x14\x39\xa2\xd5\x68\xaf\x2a\x39\x62\x45\x8e\x5f\x71\x5d\x8a\x6c\xb1\x02\xd6\x7e\x1a\x00\xa8\xcb\x53\x91\xb6\x10\x00\x00")
 
 func assetsHtmlIndexHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -147,7 +147,7 @@ func assetsHtmlIndexHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/html/index.html", size: 4043, mode: os.FileMode(420), modTime: time.Unix(1618997420, 0)}
+	info := bindataFileInfo{name: "assets/html/index.html", size: 4278, mode: os.FileMode(420), modTime: time.Unix(1792306322, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -265,23 +265,25 @@ func AssetNames() []string {
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
 	"assets/html/clash-config-local.yaml": assetsHtmlClashConfigLocalYaml,
-	"assets/html/clash-config.yaml": assetsHtmlClashConfigYaml,
-	"assets/html/clash.html": assetsHtmlClashHtml,
-	"assets/html/index.html": assetsHtmlIndexHtml,
-	"assets/html/surge.conf": assetsHtmlSurgeConf,
-	"assets/html/surge.html": assetsHtmlSurgeHtml,
-	"assets/css/index.css": assetsCssIndexCss,
+	"assets/html/clash-config.yaml":       assetsHtmlClashConfigYaml,
+	"assets/html/clash.html":              assetsHtmlClashHtml,
+	"assets/html/index.html":              assetsHtmlIndexHtml,
+	"assets/html/surge.conf":              assetsHtmlSurgeConf,
+	"assets/html/surge.html":              assetsHtmlSurgeHtml,
+	"assets/css/index.css":                assetsCssIndexCss,
 }
 
 // AssetDir returns the file names below a certain
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"}
 // AssetDir("data/img") would return []string{"a.png", "b.png"}
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error
@@ -312,6 +314,7 @@ type bintree struct {
 	Func     func() (*asset, error)
 	Children map[string]*bintree
 }
+
 var _bintree = &bintree{nil, map[string]*bintree{
 	"assets": &bintree{nil, map[string]*bintree{
 		"css": &bintree{nil, map[string]*bintree{
@@ -319,11 +322,11 @@ var _bintree = &bintree{nil, map[string]*bintree{
 		}},
 		"html": &bintree{nil, map[string]*bintree{
 			"clash-config-local.yaml": &bintree{assetsHtmlClashConfigLocalYaml, map[string]*bintree{}},
-			"clash-config.yaml": &bintree{assetsHtmlClashConfigYaml, map[string]*bintree{}},
-			"clash.html": &bintree{assetsHtmlClashHtml, map[string]*bintree{}},
-			"index.html": &bintree{assetsHtmlIndexHtml, map[string]*bintree{}},
-			"surge.conf": &bintree{assetsHtmlSurgeConf, map[string]*bintree{}},
-			"surge.html": &bintree{assetsHtmlSurgeHtml, map[string]*bintree{}},
+			"clash-config.yaml":       &bintree{assetsHtmlClashConfigYaml, map[string]*bintree{}},
+			"clash.html":              &bintree{assetsHtmlClashHtml, map[string]*bintree{}},
+			"index.html":              &bintree{assetsHtmlIndexHtml, map[string]*bintree{}},
+			"surge.conf":              &bintree{assetsHtmlSurgeConf, map[string]*bintree{}},
+			"surge.html":              &bintree{assetsHtmlSurgeHtml, map[string]*bintree{}},
 		}},
 	}},
 }}
@@ -374,4 +377,3 @@ func _filePath(dir, name string) string {
 	cannonicalName := strings.Replace(name, "\\", "/", -1)
 	return filepath.Join(append([]string{dir}, strings.Split(cannonicalName, "/")...)...)
 }
-
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	appcache "github.com/qiuchao/proxypoolCheck/internal/cache"
+	"github.com/qiuchao/proxypoolCheck/internal/provider"
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
+)
+
+// proxyItem is one proxy of /api/proxies
+type proxyItem struct {
+	Name        string   `json:"name"`
+	Identifier  string   `json:"identifier"`
+	Type        string   `json:"type"`
+	Server      string   `json:"server"`
+	IP          string   `json:"ip"`      // the tested address
+	IPs         []string `json:"ips"`     // all the resolved addresses
+	IPv4OK      *bool    `json:"ipv4_ok"` // null for not tested
+	IPv6OK      *bool    `json:"ipv6_ok"`
+	Port        int      `json:"port"`
+	Country     string   `json:"country"`
+	CountryCode string   `json:"country_code"`
+	CountryName string   `json:"country_name"`
+	Region      string   `json:"region"`
+	DelayMs     int64    `json:"delay_ms"`
+	Bandwidth   float64  `json:"bandwidth"`
+	TTFBMs      int64    `json:"ttfb_ms"`
+	Score       int      `json:"score"`        // reputation score 0-100, -1 for no record
+	Reputation  string   `json:"reputation"`   // good or probation, pin when pinned by hand
+	LastChecked string   `json:"last_checked"` // of the healthcheck, a result within check_ttl is reused
+	Source      string   `json:"source"`
+	SourceKind  string   `json:"source_kind"`
+}
+
+type proxiesResponse struct {
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	Size    int         `json:"size"`
+	Proxies []proxyItem `json:"proxies"`
+}
+
+// 排序字段, 未知的值总是排在最后
+var proxySorts = map[string]struct {
+	less  func(a, b *proxyItem) bool
+	known func(p *proxyItem) bool
+}{
+	"name":      {func(a, b *proxyItem) bool { return a.Name < b.Name }, func(p *proxyItem) bool { return true }},
+	"type":      {func(a, b *proxyItem) bool { return a.Type < b.Type }, func(p *proxyItem) bool { return true }},
+	"country":   {func(a, b *proxyItem) bool { return a.CountryCode < b.CountryCode }, func(p *proxyItem) bool { return p.CountryCode != "" }},
+	"delay":     {func(a, b *proxyItem) bool { return a.DelayMs < b.DelayMs }, func(p *proxyItem) bool { return p.DelayMs > 0 }},
+	"ttfb":      {func(a, b *proxyItem) bool { return a.TTFBMs < b.TTFBMs }, func(p *proxyItem) bool { return p.TTFBMs > 0 }},
+	"bandwidth": {func(a, b *proxyItem) bool { return a.Bandwidth < b.Bandwidth }, func(p *proxyItem) bool { return p.Bandwidth > 0 }},
+	"score":     {func(a, b *proxyItem) bool { return a.Score < b.Score }, func(p *proxyItem) bool { return p.Score >= 0 }},
+}
+
+// /api/proxies?type=&c=&nc=&speed=&filter=&source=&family=ipv6&sort=delay&order=asc&page=1&size=50
+func proxiesHandler(c *gin.Context) {
+	sortKey := c.DefaultQuery("sort", "")
+	by, ok := proxySorts[sortKey]
+	if sortKey != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown sort: " + sortKey})
+		return
+	}
+	desc := strings.ToLower(c.DefaultQuery("order", "asc")) == "desc"
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "0"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	state := appcache.Current()
+	// the filters change names, work on copies of the cached proxies
+	copies := state.CloneProxies()
+	filtered := provider.Base{
+		Proxies:    &copies,
+		Types:      c.DefaultQuery("type", ""),
+		Country:    c.DefaultQuery("c", ""),
+		NotCountry: c.DefaultQuery("nc", ""),
+		Speed:      c.DefaultQuery("speed", ""),
+		Filter:     c.DefaultQuery("filter", ""),
+		Source:     c.DefaultQuery("source", ""),
+		Family:     c.DefaultQuery("family", ""),
+		Infos:      state.ProxyInfos,
+		Speeds:     state.Speeds,
+	}.Filtered()
+
+	infos := state.ProxyInfos
+	items := make([]proxyItem, 0, len(filtered))
+	for _, p := range filtered {
+		item := newProxyItem(p, infos, state.Reputation)
+		if state.Overrides[p.Identifier()] == reputation.Pin {
+			item.Reputation = reputation.Pin
+		}
+		if r, ok := state.Checks[p.Identifier()]; ok && !r.LastChecked.IsZero() {
+			item.LastChecked = r.LastChecked.In(config.Get().Location()).Format("2006-01-02 15:04:05")
+		}
+		items = append(items, item)
+	}
+
+	if ok {
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := &items[i], &items[j]
+			aKnown, bKnown := by.known(a), by.known(b)
+			if aKnown != bKnown || !aKnown {
+				return aKnown && !bKnown
+			}
+			if desc {
+				return by.less(b, a)
+			}
+			return by.less(a, b)
+		})
+	}
+
+	resp := proxiesResponse{Total: len(items), Page: page, Size: size, Proxies: items}
+	if size > 0 {
+		start := (page - 1) * size
+		if start > len(items) {
+			start = len(items)
+		}
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		resp.Proxies = items[start:end]
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func newProxyItem(p proxy.Proxy, infos map[string]appcache.ProxyInfo, records map[string]reputation.Record) proxyItem {
+	base := p.BaseInfo()
+	info, ok := infos[p.Identifier()]
+	if !ok {
+		info = appcache.ProxyInfo{Bandwidth: -1, TTFBMs: -1}
+	}
+	score, state := -1, ""
+	if r, ok := records[p.Identifier()]; ok {
+		score, state = r.Score(), r.State
+	}
+	server := info.Host
+	if server == "" {
+		server = base.Server
+	}
+	return proxyItem{
+		Name:        base.Name,
+		Identifier:  p.Identifier(),
+		Type:        p.TypeName(),
+		Server:      server,
+		IP:          base.Server,
+		IPs:         info.IPs,
+		IPv4OK:      info.IPv4OK,
+		IPv6OK:      info.IPv6OK,
+		Port:        base.Port,
+		Country:     base.Country,
+		CountryCode: info.CountryCode,
+		CountryName: info.CountryName,
+		Region:      info.Region,
+		DelayMs:     info.DelayMs,
+		Bandwidth:   info.Bandwidth,
+		TTFBMs:      info.TTFBMs,
+		Score:       score,
+		Reputation:  state,
+		Source:      config.MaskURL(info.Source),
+		SourceKind:  info.SourceKind,
+	}
+}
+
+// sourceItem is one source of /api/sources
+type sourceItem struct {
+	Url string `json:"url"`
+	appcache.SourceStat
+	SurvivalRate     float64 `json:"survival_rate"`      // survived / fetched of all runs
+	LastSurvivalRate float64 `json:"last_survival_rate"` // of the last run
+}
+
+// /api/sources lists the sources with their survival rates, the worst first
+func sourcesHandler(c *gin.Context) {
+	stats := appcache.Current().SourceStats
+	items := make([]sourceItem, 0, len(stats))
+	for url, stat := range stats {
+		item := sourceItem{Url: config.MaskURL(url), SourceStat: stat}
+		item.LastError = strings.ReplaceAll(stat.LastError, url, item.Url)
+		if stat.Fetched > 0 {
+			item.SurvivalRate = float64(stat.Survived) / float64(stat.Fetched)
+		}
+		if stat.LastFetched > 0 {
+			item.LastSurvivalRate = float64(stat.LastSurvived) / float64(stat.LastFetched)
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].SurvivalRate != items[j].SurvivalRate {
+			return items[i].SurvivalRate < items[j].SurvivalRate
+		}
+		return items[i].Url < items[j].Url
+	})
+	c.JSON(http.StatusOK, items)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/internal/app"
+	appcache "github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+const tokenURL = "https://sub.example.com/api/v1/client/subscribe?token=s3cret"
+
+func get(t *testing.T, handler gin.HandlerFunc, target string) string {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	handler(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d", target, w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestSourceURLsMasked(t *testing.T) {
+	v, err := app.ParseVlessLink("vless://uuid@1.2.3.4:443#a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := appcache.Current()
+	t.Cleanup(func() {
+		appcache.Update(func(s *appcache.State) { *s = *last })
+	})
+	appcache.Update(func(s *appcache.State) {
+		s.Proxies = proxy.ProxyList{v}
+		s.ProxyInfos = map[string]appcache.ProxyInfo{v.Identifier(): {Source: tokenURL, SourceKind: "subscription"}}
+		s.SourceStats = map[string]appcache.SourceStat{tokenURL: {
+			Kind:      "subscription",
+			LastError: `Get "` + tokenURL + `": dial tcp: i/o timeout`,
+		}}
+	})
+
+	for _, target := range []string{"/api/sources", "/api/proxies"} {
+		handler := sourcesHandler
+		if target == "/api/proxies" {
+			handler = proxiesHandler
+		}
+		body := get(t, handler, target)
+		if strings.Contains(body, "s3cret") {
+			t.Errorf("%s leaks the token: %s", target, body)
+		}
+		if !strings.Contains(body, "https://sub.example.com/api/v1/client/subscribe?token=***") {
+			t.Errorf("%s has no masked url: %s", target, body)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypoolCheck/internal/app"
+	appcache "github.com/qiuchao/proxypoolCheck/internal/cache"
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
+)
+
+// reputationItem is one node of /api/reputation
+type reputationItem struct {
+	Identifier string `json:"identifier"`
+	reputation.Record
+	Score    int    `json:"score"`
+	Override string `json:"override,omitempty"` // pin or ban
+}
+
+// /api/reputation?state=quarantine lists the nodes with a record or an override, the worst first
+func reputationHandler(c *gin.Context) {
+	state := appcache.Current()
+	filter := c.DefaultQuery("state", "")
+	items := make([]reputationItem, 0, len(state.Reputation))
+	for id, r := range state.Reputation {
+		items = append(items, reputationItem{Identifier: id, Record: r, Score: r.Score(), Override: state.Overrides[id]})
+	}
+	for id, o := range state.Overrides {
+		if _, ok := state.Reputation[id]; !ok {
+			items = append(items, reputationItem{Identifier: id, Score: -1, Override: o})
+		}
+	}
+	if filter != "" {
+		filtered := items[:0]
+		for _, item := range items {
+			if item.State == filter || item.Override == filter {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score < items[j].Score
+		}
+		return items[i].Identifier < items[j].Identifier
+	})
+	c.JSON(http.StatusOK, items)
+}
+
+type overrideRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+	Action     string `json:"action" binding:"required"` // pin, ban or clear
+}
+
+// POST /api/reputation/override {"identifier": "...", "action": "ban"} pins or bans a node by hand
+func overrideHandler(c *gin.Context) {
+	var req overrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := app.SetOverride(req.Identifier, req.Action); err != nil {
+		if err == app.ErrBadOverride {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// the override is set, only saving failed
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save cache: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"identifier": req.Identifier, "action": req.Action})
+}
@@ -2,90 +2,34 @@ package api
 
 import (
 	"html/template"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
-	"io/ioutil"
 
-	"github.com/qiuchao/proxypool/pkg/provider"
-	"github.com/qiuchao/proxypoolCheck/config"
-	"github.com/qiuchao/proxypoolCheck/internal/app"
-	appcache "github.com/qiuchao/proxypoolCheck/internal/cache"
 	"github.com/gin-contrib/cache"
 	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
-	"github.com/qiuchao/proxypool/pkg/tool"
-	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/app"
+	appcache "github.com/qiuchao/proxypoolCheck/internal/cache"
+	"github.com/qiuchao/proxypoolCheck/internal/cron"
+	"github.com/qiuchao/proxypoolCheck/internal/metrics"
+	"github.com/qiuchao/proxypoolCheck/internal/provider"
 )
 
 const version = "v0.7.3"
 
 var router *gin.Engine
 
-var ssrObfsList = []string{
-	"plain",
-	"http_simple",
-	"http_post",
-	"random_head",
-	"tls1.2_ticket_auth",
-	"tls1.2_ticket_fastauth",
-}
-
-var ssrProtocolList = []string{
-	"origin",
-	"verify_deflate",
-	"verify_sha1",
-	"auth_sha1",
-	"auth_sha1_v2",
-	"auth_sha1_v4",
-	"auth_aes128_md5",
-	"auth_aes128_sha1",
-	"auth_chain_a",
-	"auth_chain_b",
-}
-
-var vmessCipherList = []string{
-	"auto",
-	"aes-128-gcm",
-	"chacha20-poly1305",
-	"none",
-}
-
-// 检查单个节点的加密方式、协议类型与混淆是否是Clash所支持的
-func checkClashSupport(p proxy.Proxy) bool {
-	switch p.TypeName() {
-	case "ssr":
-		ssr := p.(*proxy.ShadowsocksR)
-		if tool.CheckInList(proxy.SSRCipherList, ssr.Cipher) &&
-			tool.CheckInList(ssrProtocolList, ssr.Protocol) &&
-			tool.CheckInList(ssrObfsList, ssr.Obfs) {
-			return true
-		}
-	case "vmess":
-		vmess := p.(*proxy.Vmess)
-		if tool.CheckInList(vmessCipherList, vmess.Cipher) {
-			return true
-		}
-	case "ss":
-		ss := p.(*proxy.Shadowsocks)
-		if tool.CheckInList(proxy.SSCipherList, ss.Cipher) {
-			return true
-		}
-	case "trojan":
-		return true
-	default:
-		return false
-	}
-	return false
-}
-
 func setupRouter() {
 	gin.SetMode(gin.ReleaseMode)
 	router = gin.New() // 没有任何中间件的路由
 	store := persistence.NewInMemoryStore(time.Minute)
-	router.Use(gin.Recovery(), cache.SiteCache(store, time.Minute))
+	router.Use(gin.Recovery(), siteCache(store))
 
 	_ = RestoreAssets("", "assets/html")
 	_ = RestoreAssets("", "assets/css")
@@ -97,33 +41,41 @@ func setupRouter() {
 	router.SetHTMLTemplate(temp)
 	router.StaticFile("/css/index.css", "assets/css/index.css")
 	router.GET("/", func(c *gin.Context) {
+		conf := config.Get()
+		state := appcache.Current()
 		c.HTML(http.StatusOK, "assets/html/index.html", gin.H{
-			"domain":               config.Config.Domain,
-			"request":              config.Config.Request,
-			"port":                 config.Config.Port,
-			"all_proxies_count":    appcache.AllProxiesCount,
-			"ss_proxies_count":     appcache.SSProxiesCount,
-			"ssr_proxies_count":    appcache.SSRProxiesCount,
-			"vmess_proxies_count":  appcache.VmessProxiesCount,
-			"trojan_proxies_count": appcache.TrojanProxiesCount,
-			"useful_proxies_count": appcache.UsableProxiesCount,
-			"last_crawl_time":      appcache.LastCrawlTime,
-			"version":              version,
+			"domain":                  conf.Domain,
+			"request":                 conf.Request,
+			"port":                    conf.Port,
+			"all_proxies_count":       state.AllProxiesCount,
+			"ss_proxies_count":        state.SSProxiesCount,
+			"ssr_proxies_count":       state.SSRProxiesCount,
+			"vmess_proxies_count":     state.VmessProxiesCount,
+			"trojan_proxies_count":    state.TrojanProxiesCount,
+			"vless_proxies_count":     state.VlessProxiesCount,
+			"hysteria2_proxies_count": state.Hysteria2ProxiesCount,
+			"tuic_proxies_count":      state.TuicProxiesCount,
+			"useful_proxies_count":    state.UsableProxiesCount,
+			"last_crawl_time":         state.LastCrawlTime,
+			"next_run_time":           nextRunTime(),
+			"version":                 version,
 		})
 	})
 	router.GET("/clash", func(c *gin.Context) {
+		conf := config.Get()
 		c.HTML(http.StatusOK, "assets/html/clash.html", gin.H{
-			"domain":  config.Config.Domain,
-			"port":    config.Config.Port,
-			"request": config.Config.Request,
+			"domain":  conf.Domain,
+			"port":    conf.Port,
+			"request": conf.Request,
 		})
 	})
 
 	router.GET("/surge", func(c *gin.Context) {
+		conf := config.Get()
 		c.HTML(http.StatusOK, "assets/html/surge.html", gin.H{
-			"domain":  config.Config.Domain,
-			"request": config.Config.Request,
-			"port":    config.Config.Port,
+			"domain":  conf.Domain,
+			"request": conf.Request,
+			"port":    conf.Port,
 		})
 	})
 
@@ -137,9 +89,9 @@ func setupRouter() {
 		var resultBuilder strings.Builder
 		resultBuilder.WriteString("proxies:\n")
 		countMap := make(map[string][]string)
-		allProxies := appcache.GetProxies("proxies")
-		for _, p := range allProxies {			
-			if checkClashSupport(p) {
+		allProxies := appcache.Current().Proxies
+		for _, p := range allProxies {
+			if provider.CheckClashSupport(p) {
 				country := p.BaseInfo().Country
 				countMap[country] = append(countMap[country], p.BaseInfo().Name)
 				nameList = append(nameList, p.BaseInfo().Name)
@@ -174,9 +126,9 @@ func setupRouter() {
 		nameList := []string{}
 		var resultBuilder strings.Builder
 		resultBuilder.WriteString("proxies:\n")
-		allProxies := appcache.GetProxies("proxies")
-		for _, p := range allProxies {			
-			if checkClashSupport(p) {
+		allProxies := appcache.Current().Proxies
+		for _, p := range allProxies {
+			if provider.CheckClashSupport(p) {
 				nameList = append(nameList, p.BaseInfo().Name)
 				resultBuilder.WriteString("    " + p.ToClash() + "\n")
 			}
@@ -192,133 +144,108 @@ func setupRouter() {
 	})
 
 	router.GET("/clash/config", func(c *gin.Context) {
+		conf := config.Get()
 		c.HTML(http.StatusOK, "assets/html/clash-config.yaml", gin.H{
-			"domain":  config.Config.Domain,
-			"request": config.Config.Request,
-			"port":    config.Config.Port,
+			"domain":  conf.Domain,
+			"request": conf.Request,
+			"port":    conf.Port,
 		})
 	})
 	router.GET("/clash/localconfig", func(c *gin.Context) {
 		c.HTML(http.StatusOK, "assets/html/clash-config-local.yaml", gin.H{
-			"port": config.Config.Port,
+			"port": config.Get().Port,
 		})
 	})
 	router.GET("/clash/proxies", func(c *gin.Context) {
-		proxyTypes := c.DefaultQuery("type", "")
-		proxyCountry := c.DefaultQuery("c", "")
-		proxyNotCountry := c.DefaultQuery("nc", "")
-		proxySpeed := c.DefaultQuery("speed", "")
-		proxyFilter := c.DefaultQuery("filter", "")
-		text := ""
-		if proxyTypes == "" && proxyCountry == "" && proxyNotCountry == "" && proxySpeed == "" && proxyFilter == "" {
-			text = appcache.GetString("clashproxies") // A string. To show speed in this if condition, this must be updated after speedtest
-			if text == "" {
-				proxies := appcache.GetProxies("proxies")
-				clash := provider.Clash{
-					Base: provider.Base{
-						Proxies: &proxies,
-					},
-				}
-				text = clash.Provide() // 根据Query筛选节点
-				appcache.SetString("clashproxies", text)
-			}
-		} else if proxyTypes == "all" {
-			proxies := appcache.GetProxies("allproxies")
-			clash := provider.Clash{
-				provider.Base{
-					Proxies:    &proxies,
-					Types:      proxyTypes,
-					Country:    proxyCountry,
-					NotCountry: proxyNotCountry,
-					Speed:      proxySpeed,
-					Filter:     proxyFilter,
-				},
-			}
-			text = clash.Provide() // 根据Query筛选节点
-		} else {
-			proxies := appcache.GetProxies("proxies")
-			clash := provider.Clash{
-				provider.Base{
-					Proxies:    &proxies,
-					Types:      proxyTypes,
-					Country:    proxyCountry,
-					NotCountry: proxyNotCountry,
-					Speed:      proxySpeed,
-					Filter:     proxyFilter,
-				},
-			}
-			text = clash.Provide() // 根据Query筛选节点
+		state := appcache.Current()
+		base := proxiesQuery(c, state)
+		if base.Types == "" && base.Country == "" && base.NotCountry == "" && base.Speed == "" && base.Filter == "" && base.Source == "" && base.Family == "" && state.ClashProxies != "" {
+			c.String(200, state.ClashProxies) // rendered when the state is published, with the speed in names
+			return
 		}
-		c.String(200, text)
+		c.String(200, provider.Clash{Base: base}.Provide()) // 根据Query筛选节点
 	})
 	router.GET("/surge/proxies", func(c *gin.Context) {
-		proxyTypes := c.DefaultQuery("type", "")
-		proxyCountry := c.DefaultQuery("c", "")
-		proxyNotCountry := c.DefaultQuery("nc", "")
-		proxySpeed := c.DefaultQuery("speed", "")
-		proxyFilter := c.DefaultQuery("filter", "")
-		text := ""
-		if proxyTypes == "" && proxyCountry == "" && proxyNotCountry == "" && proxySpeed == "" {
-			text = appcache.GetString("surgeproxies") // A string. To show speed in this if condition, this must be updated after speedtest
-			if text == "" {
-				proxies := appcache.GetProxies("proxies")
-				surge := provider.Surge{
-					Base: provider.Base{
-						Proxies: &proxies,
-					},
-				}
-				text = surge.Provide()
-				appcache.SetString("surgeproxies", text)
-			}
-		} else if proxyTypes == "all" {
-			proxies := appcache.GetProxies("allproxies")
-			surge := provider.Surge{
-				Base: provider.Base{
-					Proxies:    &proxies,
-					Types:      proxyTypes,
-					Country:    proxyCountry,
-					NotCountry: proxyNotCountry,
-					Speed:      proxySpeed,
-					Filter:     proxyFilter,
-				},
-			}
-			text = surge.Provide()
-		} else {
-			proxies := appcache.GetProxies("proxies")
-			surge := provider.Surge{
-				Base: provider.Base{
-					Proxies:    &proxies,
-					Types:      proxyTypes,
-					Country:    proxyCountry,
-					NotCountry: proxyNotCountry,
-					Filter:     proxyFilter,
-				},
-			}
-			text = surge.Provide()
+		state := appcache.Current()
+		base := proxiesQuery(c, state)
+		if base.Types == "" && base.Country == "" && base.NotCountry == "" && base.Speed == "" && base.Source == "" && base.Family == "" && state.SurgeProxies != "" {
+			c.String(200, state.SurgeProxies)
+			return
 		}
-		c.String(200, text)
-	})
-	router.GET("/forceupdate", func(c *gin.Context) {
-		err := app.InitApp()
-		if err != nil {
-			c.String(http.StatusOK, err.Error())
+		if base.Types != "all" {
+			base.Speed = ""
 		}
-		c.String(http.StatusOK, "Updated")
+		c.String(200, provider.Surge{Base: base}.Provide())
 	})
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/api/proxies", proxiesHandler)
+	router.GET("/api/sources", sourcesHandler)
+	router.GET("/api/reputation", reputationHandler)
+	router.POST("/api/reputation/override", adminOnly, overrideHandler)
+	router.GET("/api/rules", rulesHandler)
+	router.PUT("/api/rules", adminOnly, setRulesHandler)
+	router.GET("/api/runs", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+		c.JSON(http.StatusOK, append(app.ActiveRuns(), app.Reports(limit)...))
+	})
+	router.GET("/api/runs/:id", runHandler)
+	router.POST("/api/runs/:id/cancel", adminOnly, cancelRunHandler)
+	router.POST("/api/config/reload", adminOnly, configReloadHandler)
+	router.GET("/forceupdate", forceUpdateHandler)
+}
+
+// proxiesQuery is the filters of the query on copies of the proxies of state, the filters change the names.
+// type=all is all the usable proxies like no type
+func proxiesQuery(c *gin.Context, state *appcache.State) provider.Base {
+	proxies := state.CloneProxies()
+	return provider.Base{
+		Proxies:    &proxies,
+		Types:      c.DefaultQuery("type", ""),
+		Country:    c.DefaultQuery("c", ""),
+		NotCountry: c.DefaultQuery("nc", ""),
+		Speed:      c.DefaultQuery("speed", ""),
+		Filter:     c.DefaultQuery("filter", ""),
+		Source:     c.DefaultQuery("source", ""),
+		Family:     c.DefaultQuery("family", ""),
+		Infos:      state.ProxyInfos,
+		Speeds:     state.Speeds,
+	}
+}
+
+func nextRunTime() string {
+	next := cron.NextRun()
+	if next.IsZero() {
+		return "-"
+	}
+	return next.Format("2006-01-02 15:04:05")
+}
+
+// siteCache caches pages for a minute. The api and metrics routes are not cached, they must be fresh
+func siteCache(store persistence.CacheStore) gin.HandlerFunc {
+	pageCache := cache.SiteCache(store, time.Minute)
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") || c.Request.URL.Path == "/metrics" {
+			c.Next()
+			return
+		}
+		pageCache(c)
+	}
 }
 
 func Run() {
 	setupRouter()
-	servePort := config.Config.Port
+	config.OnReload(restartOnPortChange)
+	servePort := config.Get().Port
 	envp := os.Getenv("PORT") // envp for heroku. DO NOT SET ENV PORT IN PERSONAL SERVER UNLESS YOU KNOW WHAT YOU ARE DOING
 	if envp != "" {
 		servePort = envp
 	}
 	// Run on this server
-	err := router.Run(":" + servePort)
+	err := serve(servePort)
 	if err != nil {
 		log.Fatalf("[router.go] Web server starting failed. Make sure your port %s has not been used. \n%s", servePort, err.Error())
 	}
+	select {}
 }
 
 // 返回页面templates
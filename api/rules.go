@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/app"
+	appcache "github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// ruleSet is the allow and deny rules of the config or of the api
+type ruleSet struct {
+	Allow []config.Rule `json:"allow"`
+	Deny  []config.Rule `json:"deny"`
+}
+
+// GET /api/rules lists the rules of the config and the ones set by the api, both are used
+func rulesHandler(c *gin.Context) {
+	conf := config.Get()
+	state := appcache.Current()
+	c.JSON(http.StatusOK, gin.H{
+		"config": ruleSet{Allow: nonNil(conf.AllowRules), Deny: nonNil(conf.DenyRules)},
+		"api":    ruleSet{Allow: nonNil(state.AllowRules), Deny: nonNil(state.DenyRules)},
+	})
+}
+
+// PUT /api/rules {"allow": [...], "deny": [{"host": "*.example.com"}]} replaces the rules set by the api
+func setRulesHandler(c *gin.Context) {
+	var req ruleSet
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := app.SetRules(req.Allow, req.Deny); err != nil {
+		// the rules are set, only saving failed
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save cache: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ruleSet{Allow: nonNil(req.Allow), Deny: nonNil(req.Deny)})
+}
+
+// nonNil is rules, or [] in json for none
+func nonNil(rules []config.Rule) []config.Rule {
+	if rules == nil {
+		return []config.Rule{}
+	}
+	return rules
+}
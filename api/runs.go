@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypoolCheck/internal/app"
+)
+
+// forceUpdateHandler queues a full run and returns its id at once. A full run already queued or running is joined.
+// wait=1 answers when the run is finished, as it used to
+func forceUpdateHandler(c *gin.Context) {
+	run, joined := app.StartRun(app.RunFull, nil)
+	if c.Query("wait") == "1" {
+		if err := run.Wait(); err != nil {
+			c.String(http.StatusOK, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "Updated")
+		return
+	}
+	id := run.Report.ID
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":     id,
+		"joined": joined,
+		"status": run.Report.GetStatus(),
+		"url":    "/api/runs/" + strconv.FormatInt(id, 10),
+	})
+}
+
+// runHandler is the report of a queued, running or finished run, with the progress
+func runHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad run id"})
+		return
+	}
+	report := app.GetRun(id)
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "run not found, it may be out of run_history"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// cancelRunHandler cancels a queued or running run, "current" is the running one.
+// The run stops at the next check of its stage and saves the proxies checked so far
+func cancelRunHandler(c *gin.Context) {
+	var id int64
+	if c.Param("id") != "current" {
+		var err error
+		if id, err = strconv.ParseInt(c.Param("id"), 10, 64); err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad run id"})
+			return
+		}
+	}
+	report, err := app.CancelRun(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":     report.ID,
+		"status": report.GetStatus(),
+		"url":    "/api/runs/" + strconv.FormatInt(report.ID, 10),
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qiuchao/proxypoolCheck/config"
+)
+
+var (
+	server      *http.Server
+	serverMutex sync.Mutex
+)
+
+// serve listens on the port and replaces the running server. The old one keeps serving when the port can not be used
+func serve(port string) error {
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	old := server
+	server = &http.Server{Handler: router}
+	go func(s *http.Server) {
+		if err := s.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("[Andy] Web server on port %s error: %s", port, err)
+		}
+	}(server)
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = old.Shutdown(ctx)
+	}
+	log.Printf("[Andy] Web server listening on port %s", port)
+	return nil
+}
+
+// Shutdown stops the web server, the requests going on are waited for until ctx is done
+func Shutdown(ctx context.Context) error {
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// restartOnPortChange moves the web server to the new port. The PORT env wins over the config, nothing to do then
+func restartOnPortChange(old, new config.ConfigOptions) {
+	if old.Port == new.Port || os.Getenv("PORT") != "" {
+		return
+	}
+	// the reload may come from a request on the old server, Shutdown waits for it
+	go func() {
+		if err := serve(new.Port); err != nil {
+			log.Printf("[Andy] Move web server to port %s error, keep port %s: %s", new.Port, old.Port, err)
+		}
+	}()
+}
+
+// configReloadHandler reloads the config file, the old config is kept when the new one is bad
+func configReloadHandler(c *gin.Context) {
+	old := config.Get()
+	if err := config.Reload(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	conf := config.Get()
+	c.JSON(http.StatusOK, gin.H{
+		"reloaded":      true,
+		"cron_interval": gin.H{"old": old.CronInterval, "new": conf.CronInterval},
+		"port":          gin.H{"old": old.Port, "new": conf.Port},
+	})
+}
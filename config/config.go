@@ -1,169 +1,295 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"github.com/ghodss/yaml"
-	"io/ioutil"
-	"os"
-	"strings"
-	"net/http"
+	"github.com/qiuchao/proxypoolCheck/internal/resolver"
+	"sync"
+	"sync/atomic"
 	"time"
-	"crypto/tls"
-	"net/url"
-	"log"
 )
 
 var configFilePath = "config.yaml"
 
 // ConfigOptions is a struct that represents config files
 type ConfigOptions struct {
-	ServerUrl          []string `json:"server_url" yaml:"server_url"`
-	ClashConfigUrl     []string `json:"clash_config_url" yaml:"clash_config_url"`
-	Domain             string   `json:"domain" yaml:"domain"`
-	Port               string   `json:"port" yaml:"port"`
-	Request            string   `json:"request" yaml:"request"`
-	CronInterval       uint64   `json:"cron_interval" yaml:"cron_interval"`
-	ProxyUrl           string    `json:"proxy_url" yaml:"proxy_url"`
-	MaxProxyCount      int      `json:"max_proxy_count" yaml:"max_proxy_count"`
-	HealthCheckTimeout int      `json:"healthcheck_timeout" yaml:"healthcheck_timeout"`
-	HealthCheckConnection int 	`json:"healthcheck_connection" yaml:"healthcheck_connection"`
-	SpeedTest          bool     `json:"speedtest" yaml:"speedtest"`
-	ThirdpartSpeedtest bool     `json:"thirdpart_speedtest" yaml:"thirdpart_speedtest"`
-	SpeedConnection    int      `json:"speed_connection" yaml:"speed_connection"`
-	SpeedTimeout       int      `json:"speed_timeout" yaml:"speed_timeout"`
-	SpeedDownloadSize  int      `json:"speed_download_size" yaml:"speed_download_size"`
-	SpeedSort          int      `json:"speed_sort" yaml:"speed_sort"`
-	SpeedServer        string   `json:"speed_server" yaml:"speed_server"`
-	SpeedMinBandwidth  float64  `json:"speed_min_bandwidth" yaml:"speed_min_bandwidth"`
-	SpeedMaxTtfb       float64  `json:"speed_max_ttfb" yaml:"speed_max_ttfb"`
-	SleepStart         int      `json:"sleep_start" yaml:"sleep_start"`
-	SleepEnd           int      `json:"sleep_end" yaml:"sleep_end"`
-	FinishCmd          string   `json:"finish_cmd" yaml:"finish_cmd"`
-	ToBadProxyTimes    int      `json:"to_bad_proxy_times" yaml:"to_bad_proxy_times"`
-	SkipBadProxyTimes  int      `json:"skip_bad_proxy_times" yaml:"skip_bad_proxy_times"`
+	ServerUrl             []Source      `json:"server_url" yaml:"server_url"`
+	ClashConfigUrl        []Source      `json:"clash_config_url" yaml:"clash_config_url"`
+	SubscriptionUrl       []Source      `json:"subscription_url" yaml:"subscription_url"`
+	LocalPath             []Source      `json:"local_path" yaml:"local_path"`
+	Domain                string        `json:"domain" yaml:"domain"`
+	Port                  string        `json:"port" yaml:"port"`
+	AdminToken            string        `json:"admin_token" yaml:"admin_token"` // for the api changing things, only localhost can use them when empty
+	Request               string        `json:"request" yaml:"request"`
+	CronInterval          uint64        `json:"cron_interval" yaml:"cron_interval"`
+	Cron                  string        `json:"cron" yaml:"cron"`                         // cron expression of the full run, wins over cron_interval
+	HealthcheckCron       string        `json:"healthcheck_cron" yaml:"healthcheck_cron"` // re-healthcheck the cached proxies
+	SpeedtestCron         string        `json:"speedtest_cron" yaml:"speedtest_cron"`     // third part speed test on the cached proxies, not in the full run then
+	ProxyUrl              string        `json:"proxy_url" yaml:"proxy_url"`
+	MaxProxyCount         int           `json:"max_proxy_count" yaml:"max_proxy_count"`
+	HealthCheckTimeout    int           `json:"healthcheck_timeout" yaml:"healthcheck_timeout"`
+	HealthCheckConnection int           `json:"healthcheck_connection" yaml:"healthcheck_connection"`
+	CheckTTL              int           `json:"check_ttl" yaml:"check_ttl"` // minutes a healthcheck result is reused, 0 tests all in every run
+	SpeedTest             bool          `json:"speedtest" yaml:"speedtest"`
+	ThirdpartSpeedtest    bool          `json:"thirdpart_speedtest" yaml:"thirdpart_speedtest"`
+	SpeedConnection       int           `json:"speed_connection" yaml:"speed_connection"`
+	SpeedTimeout          int           `json:"speed_timeout" yaml:"speed_timeout"`
+	SpeedDownloadSize     int           `json:"speed_download_size" yaml:"speed_download_size"`
+	SpeedSort             int           `json:"speed_sort" yaml:"speed_sort"`
+	SpeedServer           string        `json:"speed_server" yaml:"speed_server"`
+	SpeedMinBandwidth     float64       `json:"speed_min_bandwidth" yaml:"speed_min_bandwidth"`
+	SpeedMaxTtfb          float64       `json:"speed_max_ttfb" yaml:"speed_max_ttfb"`
+	SleepStart            int           `json:"sleep_start" yaml:"sleep_start"`
+	SleepEnd              int           `json:"sleep_end" yaml:"sleep_end"`
+	Sleep                 []SleepWindow `json:"sleep_windows" yaml:"sleep_windows"`
+	Timezone              string        `json:"timezone" yaml:"timezone"`
+	FinishCmd             string        `json:"finish_cmd" yaml:"finish_cmd"`
+	ToBadProxyTimes       int           `json:"to_bad_proxy_times" yaml:"to_bad_proxy_times"`     // old option, the default of quarantine_failures
+	SkipBadProxyTimes     int           `json:"skip_bad_proxy_times" yaml:"skip_bad_proxy_times"` // old option, quarantine_minutes defaults to it times cron_interval, at most 60
+	QuarantineFailures    int           `json:"quarantine_failures" yaml:"quarantine_failures"`
+	QuarantineBelow       float64       `json:"quarantine_below" yaml:"quarantine_below"`
+	QuarantineMinutes     int           `json:"quarantine_minutes" yaml:"quarantine_minutes"`
+	ProbationPasses       int           `json:"probation_passes" yaml:"probation_passes"`
+	ReputationAlpha       float64       `json:"reputation_alpha" yaml:"reputation_alpha"`
+	ReputationForgetDays  int           `json:"reputation_forget_days" yaml:"reputation_forget_days"`
+	AllowRules            []Rule        `json:"allow_rules" yaml:"allow_rules"` // kept without the checks, unless a deny rule matches too
+	DenyRules             []Rule        `json:"deny_rules" yaml:"deny_rules"`   // dropped and never served
+	CacheType             string        `json:"cache_type" yaml:"cache_type"`
+	CacheFile             string        `json:"cache_file" yaml:"cache_file"`
+	SourceCacheDir        string        `json:"source_cache_dir" yaml:"source_cache_dir"`
+	RunHistory            int           `json:"run_history" yaml:"run_history"`
+	FetchConcurrency      int           `json:"fetch_concurrency" yaml:"fetch_concurrency"`
+	FetchRetries          *int          `json:"fetch_retries" yaml:"fetch_retries"` // nil when not set, 0 is no retry
+	FetchRetryDelay       int           `json:"fetch_retry_delay" yaml:"fetch_retry_delay"`
+	DNSServers            []string      `json:"dns_servers" yaml:"dns_servers"` // upstreams tried in order, the system resolver when empty
+	DNSConcurrency        int           `json:"dns_concurrency" yaml:"dns_concurrency"`
+	DNSTimeout            int           `json:"dns_timeout" yaml:"dns_timeout"`
+	DNSCacheTTL           int           `json:"dns_cache_ttl" yaml:"dns_cache_ttl"` // minutes, -1 for no cache
+	DNSPrefer             string        `json:"dns_prefer" yaml:"dns_prefer"`
+	DualStackCheck        bool          `json:"dual_stack_check" yaml:"dual_stack_check"` // healthcheck the alive proxies on the address of the other family too
+
+	location *time.Location // of Timezone
+}
+
+var current atomic.Pointer[ConfigOptions]
+
+func init() {
+	current.Store(&ConfigOptions{})
 }
 
-var Config ConfigOptions
+// Get is the config in use. Parse replaces it as a whole and never changes it in place, so it is safe to read
+// from any goroutine. Keep the pointer to read several options of the same config
+func Get() *ConfigOptions {
+	return current.Load()
+}
 
-// Parse Config file
+// Parse Config file. The config is replaced only when the file is good, the old one is kept on error.
+// An empty path is the last one parsed
 func Parse(path string) error {
+	swapMutex.Lock()
+	defer swapMutex.Unlock()
 	if path == "" {
 		path = configFilePath
 	} else {
 		configFilePath = path
 	}
+	return parseFile(path)
+}
+
+// parseFile is Parse with swapMutex held
+func parseFile(path string) error {
 	fileData, err := ReadFile(path)
 	if err != nil {
 		return err
 	}
-	Config = ConfigOptions{}
-	err = yaml.Unmarshal(fileData, &Config)
+	c, err := parse(fileData)
 	if err != nil {
 		return err
 	}
+	current.Store(c)
+	return nil
+}
+
+// Reload parses the config file again and calls the OnReload hooks when it has changed. The old config is kept on error
+func Reload() error {
+	// one by one, so the hooks see every change in order
+	swapMutex.Lock()
+	defer swapMutex.Unlock()
+	old := Get()
+	if err := parseFile(configFilePath); err != nil {
+		return err
+	}
+	if sameConfig(old, Get()) {
+		return nil // the cron reloads on every run, nothing to redo for the hooks then
+	}
+	reloadMutex.Lock()
+	hooks := append([]func(old, new ConfigOptions){}, reloadHooks...)
+	reloadMutex.Unlock()
+	for _, f := range hooks {
+		f(*old, *Get())
+	}
+	return nil
+}
+
+var (
+	reloadHooks []func(old, new ConfigOptions)
+	reloadMutex sync.Mutex
+	swapMutex   sync.Mutex // one reload at a time, it guards configFilePath too
+)
+
+// sameConfig compares the options as json, the compiled regexps and the location are made from them
+func sameConfig(a, b *ConfigOptions) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(x, y)
+}
+
+// OnReload registers f to be called after the config is reloaded, for what needs more than reading Get() again
+func OnReload(f func(old, new ConfigOptions)) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	reloadHooks = append(reloadHooks, f)
+}
+
+// parse the config file content, set defaults and validate
+func parse(fileData []byte) (*ConfigOptions, error) {
+	v, err := checkKeys(fileData)
+	if err != nil {
+		return nil, err
+	}
+	c := ConfigOptions{}
+	err = yaml.Unmarshal(fileData, &c)
+	if err != nil {
+		if verr := v.err(); verr != nil {
+			return nil, verr // the same error with the line number
+		}
+		return nil, err
+	}
+	c.applyOverrides(v)
 	// set default
-	if Config.ServerUrl == nil{
-		return errors.New("config error: no server url")
+	if c.ServerUrl == nil && c.ClashConfigUrl == nil && c.SubscriptionUrl == nil && c.LocalPath == nil {
+		return nil, errors.New("config error: no server url")
 	}
-	if Config.Domain == ""{
-		Config.Domain = "127.0.0.1"
+	if c.Domain == "" {
+		c.Domain = "127.0.0.1"
 	}
-	if Config.Port == ""{
-		Config.Port = "80"
+	if c.Port == "" {
+		c.Port = "80"
 	}
-	if Config.CronInterval == 0{
-		Config.CronInterval = 60
+	if c.CronInterval == 0 {
+		c.CronInterval = 60
 	}
-	if Config.MaxProxyCount == 0{
-		Config.MaxProxyCount = 50
+	if c.MaxProxyCount == 0 {
+		c.MaxProxyCount = 50
 	}
-	if Config.Request == ""{
-		Config.Request = "http"
+	if c.Request == "" {
+		c.Request = "http"
 	}
-	if Config.HealthCheckTimeout == 0{
-		Config.HealthCheckTimeout = 5
+	if c.HealthCheckTimeout == 0 {
+		c.HealthCheckTimeout = 5
 	}
-	if Config.HealthCheckConnection == 0{
-		Config.HealthCheckConnection = 100
+	if c.HealthCheckConnection == 0 {
+		c.HealthCheckConnection = 100
 	}
-	if Config.SpeedConnection == 0{
-		Config.SpeedConnection = 15
+	if c.SpeedConnection == 0 {
+		c.SpeedConnection = 15
 	}
-	if Config.SpeedTimeout == 0 {
-		Config.SpeedTimeout = 10
+	if c.SpeedTimeout == 0 {
+		c.SpeedTimeout = 10
 	}
-	if Config.SpeedDownloadSize == 0 {
-		Config.SpeedDownloadSize = 104857600
+	if c.SpeedDownloadSize == 0 {
+		c.SpeedDownloadSize = 104857600
 	}
-	if Config.SpeedSort == 0 {
-		Config.SpeedSort = 0
+	if c.SpeedSort == 0 {
+		c.SpeedSort = 0
 	}
-	if Config.SpeedServer == "" {
-		Config.SpeedServer = "https://speed.cloudflare.com/__down?bytes=%d"
+	if c.SpeedServer == "" {
+		c.SpeedServer = "https://speed.cloudflare.com/__down?bytes=%d"
 	}
-	if Config.SpeedMinBandwidth == 0 {
-		Config.SpeedMinBandwidth = 1024
+	if c.SpeedMinBandwidth == 0 {
+		c.SpeedMinBandwidth = 1024
 	}
-	if Config.SpeedMaxTtfb == 0 {
-		Config.SpeedMaxTtfb = 4096
+	if c.SpeedMaxTtfb == 0 {
+		c.SpeedMaxTtfb = 4096
 	}
-	if Config.SleepStart == 0{
-		Config.SleepStart = 0
+	if c.SleepStart == 0 {
+		c.SleepStart = 0
 	}
-	if Config.SleepEnd == 0{
-		Config.SleepEnd = 0
+	if c.SleepEnd == 0 {
+		c.SleepEnd = 0
 	}
-	if Config.ToBadProxyTimes == 0{
-		Config.ToBadProxyTimes = 3
+	if c.Timezone == "" {
+		c.Timezone = "Asia/Shanghai"
 	}
-	if Config.SkipBadProxyTimes == 0{
-		Config.SkipBadProxyTimes = 5
+	if c.ToBadProxyTimes == 0 {
+		c.ToBadProxyTimes = 3
 	}
-	return nil
+	if c.SkipBadProxyTimes == 0 {
+		c.SkipBadProxyTimes = 5
+	}
+	if c.QuarantineFailures == 0 {
+		c.QuarantineFailures = c.ToBadProxyTimes
+	}
+	if c.QuarantineBelow == 0 {
+		c.QuarantineBelow = 0.3
+	}
+	if c.QuarantineMinutes == 0 {
+		// at most an hour, a long cron_interval would quarantine a node for weeks
+		c.QuarantineMinutes = min(c.SkipBadProxyTimes*int(c.CronInterval), 60)
+	}
+	if c.ProbationPasses == 0 {
+		c.ProbationPasses = 2
+	}
+	if c.ReputationAlpha == 0 {
+		c.ReputationAlpha = 0.3
+	}
+	if c.ReputationForgetDays == 0 {
+		c.ReputationForgetDays = 7
+	}
+	if c.CacheType == "" {
+		c.CacheType = "file"
+	}
+	if c.CacheFile == "" {
+		c.CacheFile = "cache.json"
+	}
+	if c.SourceCacheDir == "" {
+		c.SourceCacheDir = "source_cache"
+	}
+	if c.RunHistory == 0 {
+		c.RunHistory = 20
+	}
+	if c.FetchConcurrency == 0 {
+		c.FetchConcurrency = 4
+	}
+	if c.FetchRetries == nil {
+		retries := 2
+		c.FetchRetries = &retries
+	}
+	if c.FetchRetryDelay == 0 {
+		c.FetchRetryDelay = 2
+	}
+	if c.DNSConcurrency == 0 {
+		c.DNSConcurrency = 32
+	}
+	if c.DNSTimeout == 0 {
+		c.DNSTimeout = 5
+	}
+	if c.DNSCacheTTL == 0 {
+		c.DNSCacheTTL = 10
+	}
+	if c.DNSPrefer == "" {
+		c.DNSPrefer = resolver.PreferIPv4
+	}
+	c.validate(v)
+	if err = v.err(); err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
-
 // 从本地文件或者http链接读取配置文件内容
 func ReadFile(path string) ([]byte, error) {
-	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
-		var proxy func(*http.Request) (*url.URL, error)
-		if Config.ProxyUrl != "" {
-			proxyUrl, err := url.Parse(Config.ProxyUrl)
-			if err != nil {
-				log.Printf("[Andy] Proxy url(%s) error, %s", Config.ProxyUrl, err)
-			} else {
-				proxy = http.ProxyURL(proxyUrl)
-			}
-		}
-
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		}
-		if proxy != nil {
-			tr.Proxy = proxy
-		}
-
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: tr,
-		}
-
-		resp, err := client.Get(path)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		return body, nil
-	} else {
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			return nil, err
-		}
-		return ioutil.ReadFile(path)
-	}
+	return ReadSource(Source{Url: path})
 }
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ParseCron parses a cron expression: 5 fields (minute hour day month weekday), or @hourly, @daily, @every 5m.
+// The time is in the timezone option
+func ParseCron(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+// FullRunSpec is the schedule of the full run: cron, or every cron_interval minutes
+func (c ConfigOptions) FullRunSpec() string {
+	if c.Cron != "" {
+		return c.Cron
+	}
+	return fmt.Sprintf("@every %dm", c.CronInterval)
+}
+
+// NextAwake is the first run of s from next on out of the sleep windows, in the configured timezone.
+// It gives up after 10000 runs, a schedule always in a window
+func (c ConfigOptions) NextAwake(s cron.Schedule, next time.Time) time.Time {
+	for i := 0; i < 10000 && c.SleepWindowAt(next) != nil; i++ {
+		next = s.Next(next)
+	}
+	return next.In(c.Location())
+}
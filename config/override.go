@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Every option of the config file can be overridden by an env var and a flag:
+// max_proxy_count is PPC_MAX_PROXY_COUNT and -max-proxy-count.
+// The order is flags > env > file > defaults. The lists take comma separated items,
+// or a yaml/json list for the object form: PPC_SERVER_URL=http://a,http://b
+const envPrefix = "PPC_"
+
+// the flags set on the command line, by config key
+var flagValues = make(map[string]string)
+
+type overrideFlag struct {
+	key    string
+	isBool bool
+}
+
+func (f *overrideFlag) String() string {
+	return ""
+}
+
+func (f *overrideFlag) Set(s string) error {
+	flagValues[f.key] = s
+	return nil
+}
+
+func (f *overrideFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// EnvName is the env var of a config key
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+// FlagName is the flag of a config key
+func FlagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// RegisterFlags adds a flag for every config option, call it before fs.Parse
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, key := range sortedKeys(configKeys) {
+		t := configKeys[key]
+		fs.Var(&overrideFlag{key: key, isBool: t.Kind() == reflect.Bool}, FlagName(key),
+			fmt.Sprintf("override %s of the config file (env %s)", key, EnvName(key)))
+	}
+}
+
+func sortedKeys(m map[string]reflect.Type) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyOverrides sets the env vars and then the flags on c, before the defaults.
+// The env vars are read every time, a reload picks up the changes
+func (c *ConfigOptions) applyOverrides(v *validator) {
+	for _, key := range sortedKeys(configKeys) {
+		if value, ok := os.LookupEnv(EnvName(key)); ok && value != "" {
+			c.override(v, key, EnvName(key), value)
+		}
+	}
+	for _, key := range sortedKeys(configKeys) {
+		if value, ok := flagValues[key]; ok {
+			c.override(v, key, "-"+FlagName(key), value)
+		}
+	}
+}
+
+func (c *ConfigOptions) override(v *validator, key string, name string, value string) {
+	field := reflect.New(configKeys[key])
+	data, err := overrideJSON(configKeys[key], value)
+	if err == nil {
+		err = json.Unmarshal(data, field.Interface())
+	}
+	if err != nil {
+		v.add(name, "bad value %q for %s: %s", value, key, strings.TrimPrefix(err.Error(), "json: "))
+		return
+	}
+	reflect.ValueOf(c).Elem().FieldByIndex(configIndex[key]).Set(field.Elem())
+	v.lines[key] = 0 // not from the file anymore
+}
+
+// overrideJSON turns the text of an env var or a flag into the json value of the field
+func overrideJSON(t reflect.Type, value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case t.Kind() == reflect.String:
+		return json.Marshal(value)
+	case t.Kind() == reflect.Slice:
+		if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "-") {
+			return yaml.YAMLToJSON([]byte(value))
+		}
+		urls := make([]string, 0)
+		for _, u := range strings.Split(value, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		return json.Marshal(urls)
+	}
+	return []byte(value), nil
+}
+
+// Masked is a copy of the config with the secrets masked for printing: passwords and query values of urls,
+// the header values of the sources, finish_cmd which often has a token in it, and admin_token
+func Masked() ConfigOptions {
+	c := *Get()
+	c.ServerUrl = maskSources(c.ServerUrl)
+	c.ClashConfigUrl = maskSources(c.ClashConfigUrl)
+	c.SubscriptionUrl = maskSources(c.SubscriptionUrl)
+	c.LocalPath = maskSources(c.LocalPath)
+	c.ProxyUrl = MaskURL(c.ProxyUrl)
+	if c.FinishCmd != "" {
+		c.FinishCmd = "***"
+	}
+	if c.AdminToken != "" {
+		c.AdminToken = "***"
+	}
+	return c
+}
+
+func maskSources(sources []Source) []Source {
+	if sources == nil {
+		return nil
+	}
+	masked := make([]Source, len(sources))
+	for i, s := range sources {
+		s.Url = MaskURL(s.Url)
+		s.ProxyUrl = MaskURL(s.ProxyUrl)
+		if s.Headers != nil {
+			headers := make(map[string]string, len(s.Headers))
+			for k := range s.Headers {
+				headers[k] = "***"
+			}
+			s.Headers = headers
+		}
+		masked[i] = s
+	}
+	return masked
+}
+
+// MaskURL hides the password (or a user alone) and the query values of a url, they often hold a token.
+// A path is returned as it is
+func MaskURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "***")
+	} else if u.User != nil {
+		u.User = url.User("***") // a token alone
+	}
+	if u.RawQuery != "" {
+		keys := make([]string, 0)
+		for k := range u.Query() {
+			keys = append(keys, url.QueryEscape(k)+"=***")
+		}
+		sort.Strings(keys)
+		u.RawQuery = strings.Join(keys, "&")
+	}
+	return strings.Replace(u.String(), "%2A%2A%2A@", "***@", 1)
+}
+
+// Effective is the config in use as yaml, with the secrets masked
+func Effective() string {
+	data, err := yaml.Marshal(Masked())
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
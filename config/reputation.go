@@ -0,0 +1,25 @@
+package config
+
+import (
+	"time"
+
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
+)
+
+// maxQuarantine caps the doubled quarantine, unless quarantine_minutes is longer itself
+const maxQuarantine = 24 * time.Hour
+
+// ReputationPolicy is the quarantine policy of the options
+func (c ConfigOptions) ReputationPolicy() reputation.Policy {
+	quarantine := time.Duration(c.QuarantineMinutes) * time.Minute
+	return reputation.Policy{
+		Alpha:              c.ReputationAlpha,
+		QuarantineFailures: c.QuarantineFailures,
+		QuarantineBelow:    c.QuarantineBelow,
+		MinChecks:          5,
+		Quarantine:         quarantine,
+		MaxQuarantine:      max(min(16*quarantine, maxQuarantine), quarantine),
+		ProbationPasses:    c.ProbationPasses,
+		Forget:             time.Duration(c.ReputationForgetDays) * 24 * time.Hour,
+	}
+}
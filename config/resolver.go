@@ -0,0 +1,18 @@
+package config
+
+import (
+	"time"
+
+	"github.com/qiuchao/proxypoolCheck/internal/resolver"
+)
+
+// ResolverOptions are the dns options for the resolver
+func (c ConfigOptions) ResolverOptions() resolver.Options {
+	return resolver.Options{
+		Servers:     c.DNSServers,
+		Concurrency: c.DNSConcurrency,
+		Timeout:     time.Duration(c.DNSTimeout) * time.Second,
+		CacheTTL:    max(time.Duration(c.DNSCacheTTL)*time.Minute, 0),
+		Prefer:      c.DNSPrefer,
+	}
+}
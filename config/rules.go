@@ -0,0 +1,151 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Rule is an item of allow_rules or deny_rules. A proxy matches when it matches all the fields set:
+//
+//	deny_rules:
+//	  - host: "*.honeypot.example"
+//	  - ip: 10.0.0.0/8
+//	  - port: 25
+//	    type: ss
+//	  - name: "(?i)test"
+//	allow_rules:
+//	  - identifier: "1.2.3.4:443xxxx"
+type Rule struct {
+	Host       string `json:"host,omitempty" yaml:"host,omitempty"` // server host, *.example.com for the subdomains
+	IP         string `json:"ip,omitempty" yaml:"ip,omitempty"`     // ip or cidr of the server, after it is resolved
+	Port       int    `json:"port,omitempty" yaml:"port,omitempty"`
+	Type       string `json:"type,omitempty" yaml:"type,omitempty"`
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"` // regexp of the name
+	Identifier string `json:"identifier,omitempty" yaml:"identifier,omitempty"`
+	Comment    string `json:"comment,omitempty" yaml:"comment,omitempty"`
+
+	name *regexp.Regexp
+	ip   *net.IPNet
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type rule Rule
+	var v rule
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = Rule(v)
+	return r.parse()
+}
+
+func (r *Rule) parse() error {
+	if r.Host == "" && r.IP == "" && r.Port == 0 && r.Type == "" && r.Name == "" && r.Identifier == "" {
+		return errors.New("empty rule, set host, ip, port, type, name or identifier")
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return fmt.Errorf("bad port %d", r.Port)
+	}
+	if r.Name != "" {
+		re, err := regexp.Compile(r.Name)
+		if err != nil {
+			return fmt.Errorf("bad name regexp %q: %s", r.Name, err)
+		}
+		r.name = re
+	}
+	if r.IP != "" {
+		cidr := r.IP
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("bad ip or cidr %q", r.IP)
+		}
+		r.ip = ipNet
+	}
+	r.Host = strings.ToLower(r.Host)
+	r.Type = strings.ToLower(r.Type)
+	return nil
+}
+
+// RuleTarget is what a rule matches of a proxy. Host is the server before it is resolved, Server the current one
+type RuleTarget struct {
+	Host       string
+	Server     string
+	Port       int
+	Type       string
+	Name       string
+	Identifier string
+}
+
+func (r Rule) Match(t RuleTarget) bool {
+	if r.Host != "" && !matchHost(r.Host, t.Host) && !matchHost(r.Host, t.Server) {
+		return false
+	}
+	if r.ip != nil && !r.matchIP(t.Host) && !r.matchIP(t.Server) {
+		return false
+	}
+	if r.Port != 0 && r.Port != t.Port {
+		return false
+	}
+	if r.Type != "" && r.Type != t.Type {
+		return false
+	}
+	if r.name != nil && !r.name.MatchString(t.Name) {
+		return false
+	}
+	if r.Identifier != "" && r.Identifier != t.Identifier {
+		return false
+	}
+	return true
+}
+
+func (r Rule) matchIP(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && r.ip.Contains(ip)
+}
+
+func matchHost(pattern string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+func (r Rule) String() string {
+	var parts []string
+	for _, f := range []struct{ k, v string }{
+		{"host", r.Host}, {"ip", r.IP}, {"type", r.Type}, {"name", r.Name}, {"identifier", r.Identifier},
+	} {
+		if f.v != "" {
+			parts = append(parts, f.k+"="+f.v)
+		}
+	}
+	if r.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port=%d", r.Port))
+	}
+	s := strings.Join(parts, " ")
+	if r.Comment != "" {
+		s += " (" + r.Comment + ")"
+	}
+	return s
+}
+
+// MatchRule is the first rule matching t, nil for none
+func MatchRule(rules []Rule, t RuleTarget) *Rule {
+	for i := range rules {
+		if rules[i].Match(t) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
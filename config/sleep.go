@@ -0,0 +1,182 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // the timezones for the systems without them, like a scratch container
+)
+
+// SleepWindow is an item of sleep_windows, the checks are skipped in it. It can be a plain "HH:MM-HH:MM" string,
+// or an object with the weekdays:
+//
+//	sleep_windows:
+//	  - "23:30-07:00"
+//	  - time: "12:00-13:30"
+//	    weekdays: [mon-fri]
+//
+// A window over midnight belongs to the day it starts, "23:30-07:00" on fri ends at 07:00 on sat.
+type SleepWindow struct {
+	Time     string   `json:"time" yaml:"time"`
+	Weekdays []string `json:"weekdays" yaml:"weekdays"` // sun, mon ... sat, or a range like mon-fri. Empty for every day
+
+	start, end int // minutes of the day
+	days       [7]bool
+}
+
+func (w *SleepWindow) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*w = SleepWindow{Time: s}
+		return w.parse()
+	}
+	type window SleepWindow
+	var v window
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*w = SleepWindow(v)
+	return w.parse()
+}
+
+// MarshalJSON writes the window without weekdays as a plain string
+func (w SleepWindow) MarshalJSON() ([]byte, error) {
+	if len(w.Weekdays) == 0 {
+		return json.Marshal(w.Time)
+	}
+	type window SleepWindow
+	return json.Marshal(window(w))
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 3 {
+		if d, ok := weekdayNames[s[:3]]; ok && strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("bad weekday %q", s)
+}
+
+func parseClock(s string) (int, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil || n != 2 || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("bad time %q, want HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+func (w *SleepWindow) parse() error {
+	parts := strings.Split(w.Time, "-")
+	if len(parts) != 2 {
+		return fmt.Errorf("bad sleep window %q, want HH:MM-HH:MM", w.Time)
+	}
+	var err error
+	if w.start, err = parseClock(parts[0]); err != nil {
+		return err
+	}
+	if w.end, err = parseClock(parts[1]); err != nil {
+		return err
+	}
+	if w.start == w.end {
+		return fmt.Errorf("empty sleep window %q", w.Time)
+	}
+	if len(w.Weekdays) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+	w.days = [7]bool{}
+	for _, item := range w.Weekdays {
+		for _, s := range strings.Split(item, ",") {
+			from, to, isRange := strings.Cut(s, "-")
+			first, err := parseWeekday(from)
+			if err != nil {
+				return err
+			}
+			last := first
+			if isRange {
+				if last, err = parseWeekday(to); err != nil {
+					return err
+				}
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (w SleepWindow) String() string {
+	if len(w.Weekdays) == 0 {
+		return w.Time
+	}
+	return w.Time + " on " + strings.Join(w.Weekdays, ",")
+}
+
+// Contains tells whether t is in the window, t should be in the configured timezone
+func (w SleepWindow) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && m >= w.start && m < w.end
+	}
+	// over midnight
+	if m >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return m < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+// SleepWindows are sleep_windows and the old sleep_start/sleep_end hours
+func (c ConfigOptions) SleepWindows() []SleepWindow {
+	windows := append([]SleepWindow{}, c.Sleep...)
+	if c.SleepStart != c.SleepEnd {
+		w := SleepWindow{Time: fmt.Sprintf("%02d:00-%02d:00", c.SleepStart, c.SleepEnd)}
+		if err := w.parse(); err == nil {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// SleepWindowAt is the window t is in, nil when t is not in any
+func (c ConfigOptions) SleepWindowAt(t time.Time) *SleepWindow {
+	t = t.In(c.Location())
+	for _, w := range c.SleepWindows() {
+		if w.Contains(t) {
+			return &w
+		}
+	}
+	return nil
+}
+
+// Location is the timezone option, the last crawl time and the sleep windows use it
+func (c ConfigOptions) Location() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
+// Now is the current time in the configured timezone
+func Now() time.Time {
+	return time.Now().In(Get().Location())
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("unknown timezone " + name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// at is a time of the week of 2024-01-01, a monday, in UTC
+func at(day time.Weekday, clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", "2024-01-01 "+clock)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, (int(day)+6)%7)
+}
+
+func window(t *testing.T, s string) SleepWindow {
+	var w SleepWindow
+	if err := json.Unmarshal([]byte(s), &w); err != nil {
+		t.Fatalf("window %s: %s", s, err)
+	}
+	return w
+}
+
+func TestSleepWindowContains(t *testing.T) {
+	tests := []struct {
+		window string
+		t      time.Time
+		want   bool
+	}{
+		// the same day
+		{`"12:00-13:30"`, at(time.Monday, "11:59"), false},
+		{`"12:00-13:30"`, at(time.Monday, "12:00"), true},
+		{`"12:00-13:30"`, at(time.Monday, "13:29"), true},
+		{`"12:00-13:30"`, at(time.Monday, "13:30"), false},
+		{`"22:00-24:00"`, at(time.Monday, "23:59"), true},
+		{`"22:00-24:00"`, at(time.Tuesday, "00:00"), false},
+
+		// over midnight
+		{`"23:30-07:00"`, at(time.Monday, "23:29"), false},
+		{`"23:30-07:00"`, at(time.Monday, "23:30"), true},
+		{`"23:30-07:00"`, at(time.Tuesday, "00:00"), true},
+		{`"23:30-07:00"`, at(time.Tuesday, "06:59"), true},
+		{`"23:30-07:00"`, at(time.Tuesday, "07:00"), false},
+
+		// over midnight on some days belongs to the day it starts
+		{`{"time": "23:30-07:00", "weekdays": ["fri"]}`, at(time.Friday, "23:45"), true},
+		{`{"time": "23:30-07:00", "weekdays": ["fri"]}`, at(time.Saturday, "03:00"), true},
+		{`{"time": "23:30-07:00", "weekdays": ["fri"]}`, at(time.Friday, "03:00"), false},
+		{`{"time": "23:30-07:00", "weekdays": ["fri"]}`, at(time.Saturday, "23:45"), false},
+		{`{"time": "23:30-07:00", "weekdays": ["sat"]}`, at(time.Sunday, "06:00"), true},
+		{`{"time": "23:30-07:00", "weekdays": ["sun"]}`, at(time.Monday, "06:00"), true},
+		{`{"time": "23:30-07:00", "weekdays": ["sun"]}`, at(time.Sunday, "06:00"), false},
+
+		// weekday ranges
+		{`{"time": "12:00-13:00", "weekdays": ["mon-fri"]}`, at(time.Friday, "12:30"), true},
+		{`{"time": "12:00-13:00", "weekdays": ["mon-fri"]}`, at(time.Saturday, "12:30"), false},
+		{`{"time": "12:00-13:00", "weekdays": ["fri-mon"]}`, at(time.Sunday, "12:30"), true},
+		{`{"time": "12:00-13:00", "weekdays": ["fri-mon"]}`, at(time.Monday, "12:30"), true},
+		{`{"time": "12:00-13:00", "weekdays": ["fri-mon"]}`, at(time.Wednesday, "12:30"), false},
+		{`{"time": "12:00-13:00", "weekdays": ["mon,wed", "Sunday"]}`, at(time.Wednesday, "12:30"), true},
+		{`{"time": "12:00-13:00", "weekdays": ["mon,wed", "Sunday"]}`, at(time.Sunday, "12:30"), true},
+		{`{"time": "12:00-13:00", "weekdays": ["mon,wed", "Sunday"]}`, at(time.Tuesday, "12:30"), false},
+	}
+	for _, tt := range tests {
+		w := window(t, tt.window)
+		if got := w.Contains(tt.t); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.window, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestSleepWindowErrors(t *testing.T) {
+	for _, s := range []string{
+		`"12:00"`,
+		`"12:00-12:00"`,
+		`"25:00-01:00"`,
+		`"12:60-13:00"`,
+		`"24:30-01:00"`,
+		`"noon-13:00"`,
+		`{"time": "12:00-13:00", "weekdays": ["xyz"]}`,
+		`{"time": "12:00-13:00", "weekdays": ["mo"]}`,
+		`{"time": "12:00-13:00", "weekdays": ["mon-funday"]}`,
+	} {
+		var w SleepWindow
+		if err := json.Unmarshal([]byte(s), &w); err == nil {
+			t.Errorf("window %s passed", s)
+		}
+	}
+}
+
+func TestSleepWindowsOld(t *testing.T) {
+	c := ConfigOptions{SleepStart: 1, SleepEnd: 6, Sleep: []SleepWindow{window(t, `"12:00-13:00"`)}}
+	windows := c.SleepWindows()
+	if len(windows) != 2 || windows[1].String() != "01:00-06:00" {
+		t.Fatalf("SleepWindows() = %v, want 12:00-13:00 and 01:00-06:00", windows)
+	}
+	if w := c.SleepWindowAt(at(time.Monday, "03:00")); w == nil || w.String() != "01:00-06:00" {
+		t.Fatalf("SleepWindowAt(03:00) = %v", w)
+	}
+}
+
+func TestNextAwake(t *testing.T) {
+	hourly, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		tz     string // UTC when empty
+		config string
+		next   time.Time
+		want   time.Time
+	}{
+		{
+			name:   "not in a window",
+			config: `sleep_windows: ["23:30-07:00"]`,
+			next:   at(time.Monday, "22:00"),
+			want:   at(time.Monday, "22:00"),
+		},
+		{
+			name:   "skips the night",
+			config: `sleep_windows: ["23:30-07:00"]`,
+			next:   at(time.Tuesday, "00:00"),
+			want:   at(time.Tuesday, "07:00"),
+		},
+		{
+			name:   "a window over midnight on friday only",
+			config: `sleep_windows: [{time: "23:30-07:00", weekdays: [fri]}]`,
+			next:   at(time.Saturday, "00:00"),
+			want:   at(time.Saturday, "07:00"),
+		},
+		{
+			name:   "the night of saturday is not in it",
+			config: `sleep_windows: [{time: "23:30-07:00", weekdays: [fri]}]`,
+			next:   at(time.Sunday, "00:00"),
+			want:   at(time.Sunday, "00:00"),
+		},
+		{
+			name:   "the weekend",
+			config: `sleep_windows: [{time: "00:00-24:00", weekdays: [sat-sun]}]`,
+			next:   at(time.Saturday, "10:00"),
+			want:   at(time.Monday, "00:00").AddDate(0, 0, 7),
+		},
+		{
+			name:   "two windows in a row",
+			config: `sleep_windows: ["01:00-03:00", "03:00-05:30"]`,
+			next:   at(time.Monday, "01:00"),
+			want:   at(time.Monday, "06:00"),
+		},
+		{
+			name:   "in the timezone",
+			tz:     "Asia/Shanghai",
+			config: `sleep_windows: ["23:30-07:00"]`,
+			next:   at(time.Monday, "16:00"), // 00:00 in Shanghai
+			want:   at(time.Monday, "23:00"), // 07:00 in Shanghai
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tz := tt.tz
+			if tz == "" {
+				tz = "UTC"
+			}
+			c, err := parse([]byte("local_path: [/tmp]\ntimezone: " + tz + "\n" + tt.config + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := c.NextAwake(hourly, tt.next.In(c.Location()))
+			if !got.Equal(tt.want) {
+				t.Fatalf("NextAwake(%s) = %s, want %s", tt.next, got, tt.want)
+			}
+			if got.Location() != c.Location() {
+				t.Fatalf("NextAwake() is in %s, want %s", got.Location(), c.Location())
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Source is an item of server_url, clash_config_url or subscription_url.
+// It can be a plain url string, or an object with its own fetch options:
+//
+//	clash_config_url:
+//	  - https://example.com/a.yaml
+//	  - url: https://example.com/b.yaml
+//	    user_agent: clash.meta
+//	    headers:
+//	      Authorization: Bearer xxx
+//	    timeout: 10
+//	    proxy_url: http://127.0.0.1:7890
+//	    tls_verify: true
+//	    enabled: false
+//	    weight: 10
+type Source struct {
+	Url       string            `json:"url" yaml:"url"`
+	UserAgent string            `json:"user_agent" yaml:"user_agent"`
+	Headers   map[string]string `json:"headers" yaml:"headers"`
+	Timeout   int               `json:"timeout" yaml:"timeout"`       // seconds, default 30
+	ProxyUrl  string            `json:"proxy_url" yaml:"proxy_url"`   // default is the global proxy_url, "direct" for no proxy
+	TLSVerify bool              `json:"tls_verify" yaml:"tls_verify"` // default false, the certificate is not verified
+	Enabled   *bool             `json:"enabled" yaml:"enabled"`       // default true
+	Weight    int               `json:"weight" yaml:"weight"`         // the proxy from the source with a higher weight wins in dedup
+}
+
+// UnmarshalJSON accepts a plain url string too. ghodss/yaml converts yaml to json first, so this works for yaml
+func (s *Source) UnmarshalJSON(data []byte) error {
+	var u string
+	if err := json.Unmarshal(data, &u); err == nil {
+		*s = Source{Url: u}
+		return nil
+	}
+	type source Source
+	var v source
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Url == "" {
+		return errors.New("source without url")
+	}
+	*s = Source(v)
+	return nil
+}
+
+// MarshalJSON writes a source with only the url as a plain string, the way it is usually written in the config
+func (s Source) MarshalJSON() ([]byte, error) {
+	if reflect.DeepEqual(s, Source{Url: s.Url}) {
+		return json.Marshal(s.Url)
+	}
+	type source Source
+	return json.Marshal(source(s))
+}
+
+func (s Source) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// EnabledSources drops the disabled ones
+func EnabledSources(sources []Source) []Source {
+	result := make([]Source, 0, len(sources))
+	for _, s := range sources {
+		if s.IsEnabled() {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// StatusError is returned by ReadSource when the server answers 4xx or 5xx
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %s", e.Status)
+}
+
+// Validator is what the last response of a source gave for a conditional request
+type Validator struct {
+	ETag         string
+	LastModified string
+}
+
+// SourceResponse is the result of FetchSource. NotModified means the content is the same as the validator says, Body is empty then
+type SourceResponse struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+// ReadSource reads a local file or a http(s) url with the options of the source
+func ReadSource(s Source) ([]byte, error) {
+	resp, err := FetchSource(s, Validator{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// FetchSource is ReadSource with a conditional request: If-None-Match/If-Modified-Since for http,
+// and the modification time for local files
+func FetchSource(s Source, v Validator) (*SourceResponse, error) {
+	return FetchSourceContext(context.Background(), s, v)
+}
+
+// FetchSourceContext is FetchSource, the http request is stopped when ctx is done
+func FetchSourceContext(ctx context.Context, s Source, v Validator) (*SourceResponse, error) {
+	path := s.Url
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTime := info.ModTime().UTC().Format(time.RFC3339Nano)
+		if v.LastModified == modTime {
+			return &SourceResponse{LastModified: modTime, NotModified: true}, nil
+		}
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &SourceResponse{Body: body, LastModified: modTime}, nil
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !s.TLSVerify,
+		},
+	}
+	proxyUrl := s.ProxyUrl
+	if proxyUrl == "" {
+		proxyUrl = Get().ProxyUrl
+	}
+	if proxyUrl != "" && proxyUrl != "direct" {
+		u, err := url.Parse(proxyUrl)
+		if err != nil {
+			log.Printf("[Andy] Proxy url(%s) error, %s", proxyUrl, err)
+		} else {
+			tr.Proxy = http.ProxyURL(u)
+		}
+	}
+
+	timeout := 30 * time.Second
+	if s.Timeout > 0 {
+		timeout = time.Duration(s.Timeout) * time.Second
+	}
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: tr,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.UserAgent != "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	result := &SourceResponse{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		return result, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	result.Body = body
+	return result, nil
+}
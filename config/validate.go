@@ -0,0 +1,322 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/qiuchao/proxypoolCheck/internal/resolver"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// FieldError is a problem of a config key. Line is 0 when the key is not in the file
+type FieldError struct {
+	Line int
+	Key  string
+	Msg  string
+}
+
+func (e FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Key, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Key, e.Msg)
+}
+
+// ValidationError is all the problems found in a config file, sorted by line
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return "config error:\n" + strings.Join(msgs, "\n")
+}
+
+// keyLines maps the keys to their lines in the file. Source and rule items are "server_url[0]", their keys "server_url[0].timeout"
+type keyLines map[string]int
+
+type validator struct {
+	lines keyLines
+	errs  ValidationError
+}
+
+func (v *validator) add(key string, format string, a ...interface{}) {
+	v.errs = append(v.errs, FieldError{Line: v.lines[key], Key: key, Msg: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		return v.errs[i].Line < v.errs[j].Line
+	})
+	return v.errs
+}
+
+var (
+	configKeys, configIndex = jsonFields(reflect.TypeOf(ConfigOptions{}))
+	sourceKeys, _           = jsonFields(reflect.TypeOf(Source{}))
+	ruleKeys, _             = jsonFields(reflect.TypeOf(Rule{}))
+
+	// the keys of the items of the lists of objects
+	itemKeys = map[reflect.Type]map[string]reflect.Type{
+		reflect.TypeOf([]Source{}): sourceKeys,
+		reflect.TypeOf([]Rule{}):   ruleKeys,
+	}
+)
+
+// jsonFields maps the json names of the struct fields to their types and indexes
+func jsonFields(t reflect.Type) (map[string]reflect.Type, map[string][]int) {
+	fields := make(map[string]reflect.Type)
+	index := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = f.Type
+			index[name] = f.Index
+		}
+	}
+	return fields, index
+}
+
+// checkKeys finds unknown keys and values of a wrong type, with the line numbers.
+// The error is only for a broken yaml, the problems are collected in the validator for the later checks
+func checkKeys(fileData []byte) (*validator, error) {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(fileData, &root); err != nil {
+		return nil, err
+	}
+	v := &validator{lines: make(keyLines)}
+	if len(root.Content) == 0 {
+		return v, nil // empty file
+	}
+	doc := root.Content[0]
+	if doc.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("config error: line %d: the config must be a mapping of keys", doc.Line)
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		k, value := doc.Content[i], doc.Content[i+1]
+		v.lines[k.Value] = k.Line
+		t, ok := configKeys[k.Value]
+		if !ok {
+			v.unknownKey(k.Value, k.Value, configKeys)
+			continue
+		}
+		if known, ok := itemKeys[t]; ok && value.Kind == yamlv3.SequenceNode {
+			for j, item := range value.Content {
+				v.checkItem(fmt.Sprintf("%s[%d]", k.Value, j), item, t.Elem(), known)
+			}
+			continue
+		}
+		if value.Kind == yamlv3.SequenceNode {
+			for j, item := range value.Content {
+				v.lines[fmt.Sprintf("%s[%d]", k.Value, j)] = item.Line
+			}
+		}
+		v.checkType(k.Value, value, t)
+	}
+	return v, nil
+}
+
+// checkItem checks an item of a list of sources or rules
+func (v *validator) checkItem(key string, item *yamlv3.Node, t reflect.Type, known map[string]reflect.Type) {
+	v.lines[key] = item.Line
+	if item.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			k := item.Content[i]
+			v.lines[key+"."+k.Value] = k.Line
+			if _, ok := known[k.Value]; !ok {
+				v.unknownKey(key+"."+k.Value, k.Value, known)
+			}
+		}
+	}
+	v.checkType(key, item, t)
+}
+
+func (v *validator) unknownKey(key string, name string, known map[string]reflect.Type) {
+	if s := closestKey(name, known); s != "" {
+		v.add(key, "unknown key, did you mean %s?", s)
+		return
+	}
+	v.add(key, "unknown key")
+}
+
+// checkType decodes the value alone the same way Parse does, to tell which key has a wrong type
+func (v *validator) checkType(key string, value *yamlv3.Node, t reflect.Type) {
+	data, err := yamlv3.Marshal(value)
+	if err != nil {
+		v.add(key, "%s", err)
+		return
+	}
+	if err = yaml.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+		msg := strings.TrimPrefix(err.Error(), "error unmarshaling JSON: ")
+		msg = strings.TrimPrefix(msg, "json: ")
+		v.add(key, "%s", msg)
+	}
+}
+
+// closestKey suggests the known key for a typo, "" when nothing is close
+func closestKey(name string, known map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for k := range known {
+		if d := editDistance(name, k); d < bestDist || (d == bestDist && best != "" && k < best) {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// validate checks the values after the defaults are set
+func (c *ConfigOptions) validate(v *validator) {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		v.add("port", "must be a port number 1-65535, got %q", c.Port)
+	}
+	if c.Request != "http" && c.Request != "https" {
+		v.add("request", "must be http or https, got %q", c.Request)
+	}
+	if c.ProxyUrl != "" {
+		v.checkProxyUrl("proxy_url", c.ProxyUrl)
+	}
+	v.positive("max_proxy_count", c.MaxProxyCount)
+	v.positive("healthcheck_timeout", c.HealthCheckTimeout)
+	v.positive("healthcheck_connection", c.HealthCheckConnection)
+	if c.CheckTTL < 0 {
+		v.add("check_ttl", "must not be negative, got %d", c.CheckTTL)
+	}
+	v.positive("speed_connection", c.SpeedConnection)
+	v.positive("speed_timeout", c.SpeedTimeout)
+	v.positive("speed_download_size", c.SpeedDownloadSize)
+	if c.SpeedSort < 0 || c.SpeedSort > 2 {
+		v.add("speed_sort", "must be 0 (name), 1 (bandwidth) or 2 (delay), got %d", c.SpeedSort)
+	}
+	if strings.Count(c.SpeedServer, "%d") != 1 {
+		v.add("speed_server", "must have one %%d for the download size, got %q", c.SpeedServer)
+	} else if u, err := url.Parse(fmt.Sprintf(c.SpeedServer, c.SpeedDownloadSize)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("speed_server", "must be a http(s) url, got %q", c.SpeedServer)
+	}
+	if c.SpeedMinBandwidth < 0 {
+		v.add("speed_min_bandwidth", "must not be negative, got %v", c.SpeedMinBandwidth)
+	}
+	if c.SpeedMaxTtfb < 0 {
+		v.add("speed_max_ttfb", "must not be negative, got %v", c.SpeedMaxTtfb)
+	}
+	if c.SleepStart < 0 || c.SleepStart > 23 {
+		v.add("sleep_start", "must be an hour 0-23, got %d", c.SleepStart)
+	}
+	if c.SleepEnd < 0 || c.SleepEnd > 23 {
+		v.add("sleep_end", "must be an hour 0-23, got %d", c.SleepEnd)
+	}
+	for key, spec := range map[string]string{
+		"cron":             c.Cron,
+		"healthcheck_cron": c.HealthcheckCron,
+		"speedtest_cron":   c.SpeedtestCron,
+	} {
+		if _, err := ParseCron(spec); spec != "" && err != nil {
+			v.add(key, "%s", err)
+		}
+	}
+	if loc, err := loadLocation(c.Timezone); err != nil {
+		v.add("timezone", "%s", err)
+	} else {
+		c.location = loc
+	}
+	v.positive("to_bad_proxy_times", c.ToBadProxyTimes)
+	v.positive("skip_bad_proxy_times", c.SkipBadProxyTimes)
+	v.positive("quarantine_failures", c.QuarantineFailures)
+	if c.QuarantineBelow < 0 || c.QuarantineBelow >= 1 {
+		v.add("quarantine_below", "must be a success rate 0-1, got %v", c.QuarantineBelow)
+	}
+	v.positive("quarantine_minutes", c.QuarantineMinutes)
+	v.positive("probation_passes", c.ProbationPasses)
+	if c.ReputationAlpha <= 0 || c.ReputationAlpha > 1 {
+		v.add("reputation_alpha", "must be more than 0 and at most 1, got %v", c.ReputationAlpha)
+	}
+	v.positive("reputation_forget_days", c.ReputationForgetDays)
+	if c.CacheType != "file" && c.CacheType != "none" {
+		v.add("cache_type", "must be file or none, got %q", c.CacheType)
+	}
+	v.positive("run_history", c.RunHistory)
+	v.positive("fetch_concurrency", c.FetchConcurrency)
+	if *c.FetchRetries < 0 {
+		v.add("fetch_retries", "must be 0 (no retry) or more, got %d", *c.FetchRetries)
+	}
+	if c.FetchRetryDelay < 0 {
+		v.add("fetch_retry_delay", "must not be negative, got %d", c.FetchRetryDelay)
+	}
+	for i, server := range c.DNSServers {
+		if err := resolver.CheckServer(server); err != nil {
+			v.add(fmt.Sprintf("dns_servers[%d]", i), "%s", err)
+		}
+	}
+	v.positive("dns_concurrency", c.DNSConcurrency)
+	v.positive("dns_timeout", c.DNSTimeout)
+	if c.DNSCacheTTL < -1 {
+		v.add("dns_cache_ttl", "must be -1 (no cache) or more, got %d", c.DNSCacheTTL)
+	}
+	switch c.DNSPrefer {
+	case resolver.PreferIPv4, resolver.PreferIPv6, resolver.IPv4Only, resolver.IPv6Only:
+	default:
+		v.add("dns_prefer", "must be ipv4, ipv6, ipv4_only or ipv6_only, got %q", c.DNSPrefer)
+	}
+
+	for key, sources := range map[string][]Source{
+		"server_url":       c.ServerUrl,
+		"clash_config_url": c.ClashConfigUrl,
+		"subscription_url": c.SubscriptionUrl,
+		"local_path":       c.LocalPath,
+	} {
+		for i, s := range sources {
+			item := fmt.Sprintf("%s[%d]", key, i)
+			if strings.TrimSpace(s.Url) == "" {
+				v.add(item, "empty url")
+			}
+			if s.Timeout < 0 {
+				v.add(item+".timeout", "must not be negative, got %d", s.Timeout)
+			}
+			if s.ProxyUrl != "" && s.ProxyUrl != "direct" {
+				v.checkProxyUrl(item+".proxy_url", s.ProxyUrl)
+			}
+		}
+	}
+}
+
+func (v *validator) positive(key string, n int) {
+	if n <= 0 {
+		v.add(key, "must be more than 0, got %d", n)
+	}
+}
+
+func (v *validator) checkProxyUrl(key string, s string) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		v.add(key, "must be a url like http://127.0.0.1:7890, got %q", s)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string // the lines of the error after the header, in order
+	}{
+		{
+			name: "unknown key with a suggestion",
+			yaml: "local_path: [/tmp]\nmax_proxy_cont: 10\n",
+			want: []string{"line 2: max_proxy_cont: unknown key, did you mean max_proxy_count?"},
+		},
+		{
+			name: "unknown key far from all",
+			yaml: "local_path: [/tmp]\nwhatever_this_is: 1\n",
+			want: []string{"line 2: whatever_this_is: unknown key"},
+		},
+		{
+			name: "unknown key of a source",
+			yaml: "server_url:\n  - url: http://a.com\n    timout: 5\n",
+			want: []string{"line 3: server_url[0].timout: unknown key, did you mean timeout?"},
+		},
+		{
+			name: "unknown key of a rule",
+			yaml: "local_path: [/tmp]\ndeny_rules:\n  - hots: a.com\n",
+			want: []string{
+				"line 3: deny_rules[0].hots: unknown key, did you mean host?",
+				"line 3: deny_rules[0]: empty rule, set host, ip, port, type, name or identifier",
+			},
+		},
+		{
+			name: "wrong type",
+			yaml: "local_path: [/tmp]\n\nmax_proxy_count: many\n",
+			want: []string{"line 3: max_proxy_count: cannot unmarshal string into Go value of type int"},
+		},
+		{
+			name: "bad rule",
+			yaml: "local_path: [/tmp]\nallow_rules:\n  - port: 80\n  - name: \"(\"\n",
+			want: []string{`line 4: allow_rules[1]: bad name regexp "("`},
+		},
+		{
+			name: "ranges, sorted by line",
+			yaml: "local_path: [/tmp]\nquarantine_below: 1.5\nport: 70000\nfetch_retries: -2\nspeed_sort: 3\n",
+			want: []string{
+				"line 2: quarantine_below: must be a success rate 0-1, got 1.5",
+				`line 3: port: must be a port number 1-65535, got "70000"`,
+				"line 4: fetch_retries: must be 0 (no retry) or more, got -2",
+				"line 5: speed_sort: must be 0 (name), 1 (bandwidth) or 2 (delay), got 3",
+			},
+		},
+		{
+			name: "fetch_retries -1 is not no retry",
+			yaml: "local_path: [/tmp]\nfetch_retries: -1\n",
+			want: []string{"line 2: fetch_retries: must be 0 (no retry) or more, got -1"},
+		},
+		{
+			name: "items of a list of strings",
+			yaml: "local_path: [/tmp]\ndns_servers:\n  - 1.1.1.1\n  - ftp://dns.example.com\n",
+			want: []string{"line 4: dns_servers[1]:"},
+		},
+		{
+			name: "source values",
+			yaml: "subscription_url:\n  - url: http://a.com\n    timeout: -1\n  - url: \" \"\n",
+			want: []string{
+				"line 3: subscription_url[0].timeout: must not be negative, got -1",
+				"line 4: subscription_url[1]: empty url",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse([]byte(tt.yaml))
+			var verr ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("parse() error = %v, want a ValidationError", err)
+			}
+			lines := strings.Split(err.Error(), "\n")[1:]
+			if len(lines) != len(tt.want) {
+				t.Fatalf("parse() error has %d problems, want %d:\n%s", len(lines), len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	c, err := parse([]byte("local_path: [/tmp]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != "80" || c.MaxProxyCount != 50 || *c.FetchRetries != 2 || c.CacheType != "file" {
+		t.Fatalf("defaults: port %q, max_proxy_count %d, fetch_retries %d, cache_type %q", c.Port, c.MaxProxyCount, *c.FetchRetries, c.CacheType)
+	}
+	c, err = parse([]byte("local_path: [/tmp]\nfetch_retries: 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *c.FetchRetries != 0 {
+		t.Fatalf("fetch_retries: 0 = %d retries", *c.FetchRetries)
+	}
+	c, err = parse([]byte("local_path: [/tmp]\ncron_interval: 30000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.ReputationPolicy(); p.Quarantine != time.Hour || p.MaxQuarantine != 16*time.Hour {
+		t.Fatalf("quarantine of a long cron_interval: %s, up to %s", p.Quarantine, p.MaxQuarantine)
+	}
+	c, err = parse([]byte("local_path: [/tmp]\nquarantine_minutes: 600\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.ReputationPolicy(); p.MaxQuarantine != 24*time.Hour {
+		t.Fatalf("quarantine_minutes: 600 is doubled up to %s", p.MaxQuarantine)
+	}
+}
+
+func TestParseBrokenYAML(t *testing.T) {
+	if _, err := parse([]byte("local_path: [/tmp\n")); err == nil {
+		t.Fatal("parse() of a broken yaml passed")
+	}
+	if _, err := parse([]byte("- a\n- b\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("parse() of a list = %v, want an error on line 1", err)
+	}
+}
+
+func TestClosestKey(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"max_proxy_cont", "max_proxy_count"},
+		{"cron_intervall", "cron_interval"},
+		{"prt", "port"},
+		{"completely_unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := closestKey(tt.name, configKeys); got != tt.want {
+			t.Errorf("closestKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
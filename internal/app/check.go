@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// checkRecords is a copy of the published check records for a run to change
+type checkRecords map[string]cache.CheckRecord
+
+// newCheckRecords copies the saved records. With keep, only the records of these proxies are kept,
+// so the proxies no source gives any more are forgotten
+func newCheckRecords(saved map[string]cache.CheckRecord, keep proxy.ProxyList) checkRecords {
+	records := make(checkRecords, len(saved))
+	if keep == nil {
+		for id, r := range saved {
+			records[id] = r
+		}
+		return records
+	}
+	for _, p := range keep {
+		if r, ok := saved[p.Identifier()]; ok {
+			records[p.Identifier()] = r
+		}
+	}
+	return records
+}
+
+// fresh tells whether the last check of p is within ttl
+func (records checkRecords) fresh(p proxy.Proxy, ttl time.Duration, now time.Time) (cache.CheckRecord, bool) {
+	r, ok := records[p.Identifier()]
+	if !ok || ttl <= 0 || r.LastChecked.IsZero() {
+		return r, false
+	}
+	return r, now.Sub(r.LastChecked) < ttl
+}
+
+// update records the result of the tested proxies, alive are the ones passed
+func (records checkRecords) update(tested proxy.ProxyList, alive proxy.ProxyList, infos proxyInfos, now time.Time) {
+	passed := make(map[proxy.Proxy]bool, len(alive))
+	for _, p := range alive {
+		passed[p] = true
+	}
+	for _, p := range tested {
+		r := records[p.Identifier()]
+		r.LastChecked = now
+		r.LastOK = passed[p]
+		if r.LastOK {
+			r.Failures = 0
+			r.DelayMs = infos.get(p).DelayMs
+			r.IPv4OK, r.IPv6OK = infos.get(p).IPv4OK, infos.get(p).IPv6OK
+		} else {
+			r.Failures++
+			r.DelayMs = 0
+			r.IPv4OK, r.IPv6OK = nil, nil
+		}
+		records[p.Identifier()] = r
+	}
+}
+
+// incrementalHealthCheck only tests the proxies without a result within check_ttl. The fresh ones which passed
+// are kept with their last delay and families, the fresh ones which failed are dropped without testing.
+// The records are published by saveResult with the proxies
+func incrementalHealthCheck(ctx context.Context, report *RunReport, proxies proxy.ProxyList, infos proxyInfos, records checkRecords) (proxy.ProxyList, error) {
+	ttl := time.Duration(config.Get().CheckTTL) * time.Minute
+	now := time.Now()
+	var stale, fresh proxy.ProxyList
+	for _, p := range proxies {
+		r, ok := records.fresh(p, ttl, now)
+		switch {
+		case !ok:
+			stale = append(stale, p)
+		case r.LastOK:
+			info := infos.get(p)
+			info.DelayMs = r.DelayMs
+			info.IPv4OK, info.IPv6OK = r.IPv4OK, r.IPv6OK
+			fresh = append(fresh, p)
+		default:
+			report.Skip(p, StageHealthcheck, fmt.Sprintf("failed %d times, last checked %s ago", r.Failures, now.Sub(r.LastChecked).Truncate(time.Second)))
+		}
+	}
+	if reused := len(proxies) - len(stale); reused > 0 {
+		report.SetReused(reused)
+		log.Printf("[Andy] Reuse %d healthcheck results within check_ttl, test %d proxies", reused, len(stale))
+	}
+
+	alive, err := healthCheck(ctx, stale)
+	if err != nil {
+		return nil, err
+	}
+	infos.setDelays(alive)
+	checkFamilies(ctx, alive, infos)
+	records.update(stale, alive, infos, now)
+	observeReputation(stale, alive, infos, now)
+	return append(alive, fresh...), nil
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// Run is a queued or running run. The runs of cron, /forceupdate, the file watcher and the stage crons
+// go one by one, a trigger of a kind already queued or running joins it instead of starting another
+type Run struct {
+	Report *RunReport
+	files  []string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Wait blocks until the run is finished and returns its error
+func (r *Run) Wait() error {
+	<-r.done
+	return r.err
+}
+
+var (
+	activeRuns = make(map[int64]*Run) // queued and running
+	nextRunID  int64
+	coordMutex sync.Mutex
+	runMutex   sync.Mutex // held by the running one
+
+	// the parent of all runs, canceled on shutdown
+	baseCtx, cancelAll = context.WithCancel(context.Background())
+)
+
+// StartRun queues a run of the kind, files are for RunFiles.
+// joined is true when a run of the same kind is already waiting (or running, except RunFiles), that one is returned
+func StartRun(kind string, files []string) (run *Run, joined bool) {
+	coordMutex.Lock()
+	defer coordMutex.Unlock()
+	for _, r := range activeRuns {
+		if r.Report.Kind != kind {
+			continue
+		}
+		status := r.Report.GetStatus()
+		if kind == RunFiles {
+			// the running one has read its files, a queued one can take more
+			if status == RunQueued {
+				r.files = mergeFiles(r.files, files)
+				r.Report.SetFiles(r.files)
+				return r, true
+			}
+			continue
+		}
+		return r, true
+	}
+	return newRun(kind, files), false
+}
+
+// startIdle starts a run only when nothing else is queued or running, nil otherwise
+func startIdle(kind string) *Run {
+	coordMutex.Lock()
+	defer coordMutex.Unlock()
+	if len(activeRuns) > 0 {
+		return nil
+	}
+	return newRun(kind, nil)
+}
+
+func newRun(kind string, files []string) *Run {
+	nextRunID++
+	report := NewRunReport(kind)
+	report.ID = nextRunID
+	report.SetFiles(files)
+	ctx, cancel := context.WithCancel(baseCtx)
+	run := &Run{Report: report, files: files, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	activeRuns[report.ID] = run
+	go run.execute()
+	return run
+}
+
+func (r *Run) execute() {
+	defer r.cancel()
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	report := r.Report
+
+	coordMutex.Lock()
+	files := r.files // no more files after it starts
+	coordMutex.Unlock()
+	report.Start(expectedStages(report.Kind))
+
+	defer func() {
+		if p := recover(); p != nil {
+			r.err = fmt.Errorf("panic in stage %s: %v", report.Stage, p)
+			log.Printf("[Andy] Run failed, %s", r.err)
+		}
+		report.Finish(r.err)
+		addReport(report)
+		if report.GetStatus() != RunSkipped {
+			observeReport(report)
+		}
+		coordMutex.Lock()
+		delete(activeRuns, report.ID)
+		coordMutex.Unlock()
+		close(r.done)
+	}()
+
+	if r.ctx.Err() != nil {
+		r.err = r.ctx.Err() // canceled in the queue
+		return
+	}
+	if cache.Current().AllProxiesCount > 0 && IsSleepTime() {
+		report.SetStatus(RunSkipped)
+		return
+	}
+	switch report.Kind {
+	case RunFull:
+		r.err = runCheck(r.ctx, report, nil)
+	case RunFiles:
+		r.err = runCheck(r.ctx, report, files)
+	case RunHealthcheck:
+		r.err = runCached(r.ctx, report, healthcheckStage)
+	case RunSpeedtest:
+		r.err = runCached(r.ctx, report, speedtestStage)
+	default:
+		r.err = fmt.Errorf("unknown run kind %s", report.Kind)
+	}
+}
+
+var ErrRunNotFound = errors.New("no such run queued or running")
+
+// CancelRun stops a queued or running run, id 0 is the running one.
+// The running one stops at the next check of its stage, what is done is saved
+func CancelRun(id int64) (*RunReport, error) {
+	coordMutex.Lock()
+	defer coordMutex.Unlock()
+	for _, r := range activeRuns {
+		if r.Report.ID == id || (id == 0 && r.Report.GetStatus() == RunRunning) {
+			log.Printf("[Andy] Cancel run %d (%s)", r.Report.ID, r.Report.Kind)
+			r.cancel()
+			return r.Report, nil
+		}
+	}
+	return nil, ErrRunNotFound
+}
+
+// Shutdown cancels all runs and waits for them, then saves the cache with what they have done.
+// The runs started after it are canceled at once
+func Shutdown(ctx context.Context) error {
+	cancelAll()
+	coordMutex.Lock()
+	runs := make([]*Run, 0, len(activeRuns))
+	for _, r := range activeRuns {
+		runs = append(runs, r)
+	}
+	coordMutex.Unlock()
+	for _, r := range runs {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return fmt.Errorf("run %d is not stopped: %w", r.Report.ID, ctx.Err())
+		}
+	}
+	// nothing changes the cache now
+	runMutex.Lock()
+	defer runMutex.Unlock()
+	return SaveCache()
+}
+
+// GetRun finds a queued, running or finished run by id
+func GetRun(id int64) *RunReport {
+	coordMutex.Lock()
+	run, ok := activeRuns[id]
+	coordMutex.Unlock()
+	if ok {
+		return run.Report
+	}
+	reportMutex.RLock()
+	defer reportMutex.RUnlock()
+	for _, r := range reports {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// ActiveRuns are the queued and running runs, the oldest first
+func ActiveRuns() []*RunReport {
+	coordMutex.Lock()
+	defer coordMutex.Unlock()
+	result := make([]*RunReport, 0, len(activeRuns))
+	for _, r := range activeRuns {
+		result = append(result, r.Report)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func mergeFiles(a []string, b []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(a)+len(b))
+	for _, f := range append(append([]string{}, a...), b...) {
+		if !seen[f] {
+			seen[f] = true
+			result = append(result, f)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
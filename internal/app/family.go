@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"net"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// checkFamilies records the address families the tested alive proxies work on. The tested address passed the healthcheck,
+// with dual_stack_check a copy on the first address of the other family is healthchecked the same way. A family is told
+// from one address only, the other addresses of it are not tried
+func checkFamilies(ctx context.Context, proxies proxy.ProxyList, infos proxyInfos) {
+	type job struct {
+		other proxy.Proxy // the copy on the other address
+		info  *cache.ProxyInfo
+		v6    bool
+	}
+	dualStack := config.Get().DualStackCheck
+	var jobs []job
+	for _, p := range proxies {
+		info := infos.get(p)
+		info.IPv4OK, info.IPv6OK = nil, nil
+		tested := net.ParseIP(p.BaseInfo().Server)
+		if tested == nil {
+			continue // not resolved
+		}
+		setFamilyOK(info, isIPv6(tested), true)
+		if !dualStack {
+			continue
+		}
+		for _, s := range info.IPs {
+			if ip := net.ParseIP(s); ip != nil && isIPv6(ip) != isIPv6(tested) {
+				other := p.Clone()
+				other.SetIP(s)
+				jobs = append(jobs, job{other, info, isIPv6(ip)})
+				break
+			}
+		}
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	others := make(proxy.ProxyList, 0, len(jobs))
+	for _, j := range jobs {
+		others = append(others, j.other)
+	}
+	alive, err := healthCheck(ctx, others)
+	if err != nil {
+		return // the ones not checked can not be told from the dead ones, all are unknown
+	}
+	ok := make(map[proxy.Proxy]bool, len(alive))
+	for _, p := range alive {
+		ok[p] = true
+	}
+	for _, j := range jobs {
+		setFamilyOK(j.info, j.v6, ok[j.other])
+	}
+}
+
+func setFamilyOK(info *cache.ProxyInfo, v6 bool, ok bool) {
+	if v6 {
+		info.IPv6OK = &ok
+	} else {
+		info.IPv4OK = &ok
+	}
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil
+}
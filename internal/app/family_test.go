@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+)
+
+func TestCheckFamilies(t *testing.T) {
+	v6, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("no ipv6 loopback")
+	}
+	defer v6.Close()
+	port := v6.Addr().(*net.TCPAddr).Port
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("local_path: [/tmp]\ndual_stack_check: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Parse(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// alive on ipv6 only, the ipv4 address has nothing listening
+	p := &Vless{Base: proxy.Base{Server: "::1", Port: port, Type: "vless"}, UUID: "uuid"}
+	infos := make(proxyInfos)
+	infos.get(p).IPs = []string{"::1", "127.0.0.1"}
+	checkFamilies(context.Background(), proxy.ProxyList{p}, infos)
+
+	info := infos.get(p)
+	if info.IPv6OK == nil || !*info.IPv6OK {
+		t.Errorf("ipv6_ok = %v, want true", info.IPv6OK)
+	}
+	if info.IPv4OK == nil || *info.IPv4OK {
+		t.Errorf("ipv4_ok = %v, want false, nothing listens on 127.0.0.1:%d", info.IPv4OK, port)
+	}
+	if p.Server != "::1" {
+		t.Errorf("the checked proxy moved to %s", p.Server)
+	}
+}
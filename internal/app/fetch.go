@@ -0,0 +1,335 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// Kinds of sources
+const (
+	SourceClashConfig  = "clash_config"
+	SourceSubscription = "subscription"
+	SourceServer       = "server"
+	SourceLocal        = "local" // a file found by local_path
+)
+
+// sourceJob is a source to fetch in a run
+type sourceJob struct {
+	kind   string
+	source config.Source
+}
+
+// fetchResult is the result of one sourceJob
+type fetchResult struct {
+	sourceJob
+	proxies   proxy.ProxyList
+	err       error // the fetch or parse error, proxies may be from the last good copy when it is set
+	fallback  bool  // proxies are from the last good copy
+	unchanged bool  // the source has not changed since the last fetch, proxies are parsed last time
+	attempts  int
+	duration  time.Duration
+}
+
+// the enabled sources in config, server urls are formatted
+func sourceJobs() []sourceJob {
+	jobs := make([]sourceJob, 0)
+	for _, s := range config.EnabledSources(config.Get().ClashConfigUrl) {
+		jobs = append(jobs, sourceJob{kind: SourceClashConfig, source: s})
+	}
+	for _, s := range config.EnabledSources(config.Get().SubscriptionUrl) {
+		jobs = append(jobs, sourceJob{kind: SourceSubscription, source: s})
+	}
+	for _, s := range config.EnabledSources(config.Get().ServerUrl) {
+		s.Url = formatURL(s.Url)
+		jobs = append(jobs, sourceJob{kind: SourceServer, source: s})
+	}
+	for _, s := range config.EnabledSources(config.Get().LocalPath) {
+		for _, file := range localFiles(s.Url) {
+			source := s
+			source.Url = file
+			jobs = append(jobs, sourceJob{kind: SourceLocal, source: source})
+		}
+	}
+	return jobs
+}
+
+// extensions of the files picked up from a local_path directory
+var localFileExts = []string{".yaml", ".yml", ".json", ".txt", ".list"}
+
+// localFiles expands a local_path item: the proxy files in a directory, or the files matching a glob pattern
+func localFiles(pattern string) []string {
+	pattern = filepath.Clean(pattern)
+	files := make([]string, 0)
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			log.Printf("[Andy] Read dir %s error: %s", pattern, err)
+			return files
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isLocalFileExt(e.Name()) {
+				files = append(files, filepath.Join(pattern, e.Name()))
+			}
+		}
+		return files
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		log.Printf("[Andy] Bad local_path %s: %s", pattern, err)
+		return files
+	}
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() {
+			files = append(files, m)
+		}
+	}
+	return files
+}
+
+func isLocalFileExt(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range localFileExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLocalPath tells whether the file is (or was, when it is removed) a source of the local_path items
+func matchLocalPath(file string) bool {
+	file = filepath.Clean(file)
+	for _, s := range config.EnabledSources(config.Get().LocalPath) {
+		pattern := filepath.Clean(s.Url)
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			if filepath.Dir(file) == pattern && isLocalFileExt(file) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchAll fetches the sources with at most fetch_concurrency at the same time, the results keep the order of jobs
+func fetchAll(ctx context.Context, jobs []sourceJob) []fetchResult {
+	conn := config.Get().FetchConcurrency
+	if conn <= 0 {
+		conn = 1
+	}
+	results := make([]fetchResult, len(jobs))
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, conn)
+	for i, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, job sourceJob) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = fetchSource(ctx, job)
+		}(i, job)
+	}
+	wg.Wait()
+	pruneSourceCopies(sourceJobs())
+	return results
+}
+
+// fetchSource reads the source with retries. An unchanged source reuses the proxies parsed last time.
+// When it still fails, the last good copy of the source is used
+func fetchSource(ctx context.Context, job sourceJob) fetchResult {
+	start := time.Now()
+	result := fetchResult{sourceJob: job}
+	path := job.source.Url
+	defer func() {
+		result.duration = time.Since(start)
+	}()
+
+	last := loadSourceCopy(job)
+	var validator config.Validator
+	if last != nil {
+		validator = config.Validator{ETag: last.ETag, LastModified: last.LastModified}
+	}
+	resp, attempts, err := fetchSourceWithRetry(ctx, job.source, validator)
+	result.attempts = attempts
+	switch {
+	case ctx.Err() != nil:
+		result.err = ctx.Err()
+		return result
+	case err != nil && last == nil:
+		result.err = err
+		return result
+	case err != nil:
+		log.Printf("[Andy] Fetch %s failed after %d attempts, use the last good copy: %s", path, attempts, err)
+		result.err = fmt.Errorf("%w, used the last good copy", err)
+		result.fallback = true
+		result.proxies = last.clone()
+		return result
+	case resp.NotModified && last != nil:
+		result.unchanged = true
+		result.proxies = last.clone()
+		return result
+	}
+
+	proxies, err := parseSource(job.kind, path, resp.Body)
+	result.proxies = proxies
+	result.err = err
+	if err == nil && len(proxies) > 0 {
+		saveSourceCopy(&sourceCopy{
+			SourceEntry: cache.SourceEntry{
+				Url:          path,
+				ETag:         resp.ETag,
+				LastModified: resp.LastModified,
+				Body:         resp.Body,
+			},
+			proxies: proxies.Clone(),
+		})
+	}
+	return result
+}
+
+// fetchSourceWithRetry retries network errors and 5xx with exponential backoff
+func fetchSourceWithRetry(ctx context.Context, source config.Source, validator config.Validator) (resp *config.SourceResponse, attempts int, err error) {
+	retries := 0
+	if r := config.Get().FetchRetries; r != nil && *r > 0 {
+		retries = *r
+	}
+	delay := time.Duration(config.Get().FetchRetryDelay) * time.Second
+	for attempts < retries+1 {
+		if attempts > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, attempts, ctx.Err()
+			}
+			delay *= 2
+		}
+		attempts++
+		resp, err = config.FetchSourceContext(ctx, source, validator)
+		if err == nil || !isRetryable(err) {
+			return
+		}
+		log.Printf("[Andy] Fetch %s error (attempt %d): %s", source.Url, attempts, err)
+	}
+	return
+}
+
+func isRetryable(err error) bool {
+	var statusErr *config.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	// errors of the http client, local file errors are not retried
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseSource turns the content of a source into proxies
+func parseSource(kind string, path string, data []byte) (proxy.ProxyList, error) {
+	var proxies proxy.ProxyList
+	var err error
+	switch kind {
+	case SourceClashConfig:
+		proxies, err = parseClashConfigProxies(data)
+	case SourceSubscription:
+		proxies = parseSubscription(string(data))
+	case SourceServer:
+		proxies, err = parseServerProxies(data)
+	case SourceLocal:
+		proxies, err = parseLocalFile(data)
+	default:
+		return nil, fmt.Errorf("unknown source kind %s", kind)
+	}
+	if err == nil && len(proxies) == 0 {
+		err = errors.New("no proxy on " + path)
+	}
+	return proxies, err
+}
+
+// sourceCopy is the last good response of a source and the proxies parsed from it
+type sourceCopy struct {
+	cache.SourceEntry
+	proxies proxy.ProxyList // never changed, the runs work on clones
+}
+
+func (c *sourceCopy) clone() proxy.ProxyList {
+	return c.proxies.Clone()
+}
+
+var (
+	sourceCopies = make(map[string]*sourceCopy)
+	sourceMutex  sync.Mutex
+)
+
+func sourceStore() cache.SourceStore {
+	store, err := cache.NewSourceStore(config.Get().CacheType, config.Get().SourceCacheDir)
+	if err != nil {
+		log.Printf("[Andy] Source cache error: %s", err)
+		return cache.NopSourceStore{}
+	}
+	return store
+}
+
+// loadSourceCopy reads the copy in memory, or the one saved on disk by the last process
+func loadSourceCopy(job sourceJob) *sourceCopy {
+	path := job.source.Url
+	sourceMutex.Lock()
+	defer sourceMutex.Unlock()
+	if c, ok := sourceCopies[path]; ok {
+		return c
+	}
+	entry, err := sourceStore().Load(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[Andy] Load source cache of %s error: %s", path, err)
+		}
+		return nil
+	}
+	proxies, err := parseSource(job.kind, path, entry.Body)
+	if err != nil {
+		return nil
+	}
+	c := &sourceCopy{SourceEntry: *entry, proxies: proxies}
+	sourceCopies[path] = c
+	return c
+}
+
+// pruneSourceCopies forgets the copies in memory of the sources not in jobs any more, like the ones removed by a reload
+func pruneSourceCopies(jobs []sourceJob) {
+	keep := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		keep[job.source.Url] = true
+	}
+	sourceMutex.Lock()
+	defer sourceMutex.Unlock()
+	for path := range sourceCopies {
+		if !keep[path] {
+			delete(sourceCopies, path)
+		}
+	}
+}
+
+func saveSourceCopy(c *sourceCopy) {
+	sourceMutex.Lock()
+	sourceCopies[c.Url] = c
+	sourceMutex.Unlock()
+	if err := sourceStore().Save(&c.SourceEntry); err != nil {
+		log.Printf("[Andy] Save source cache of %s error: %s", c.Url, err)
+	}
+}
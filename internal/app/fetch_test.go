@@ -0,0 +1,20 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/qiuchao/proxypoolCheck/config"
+)
+
+func TestPruneSourceCopies(t *testing.T) {
+	sourceMutex.Lock()
+	last := sourceCopies
+	sourceCopies = map[string]*sourceCopy{"http://kept": {}, "http://removed": {}}
+	sourceMutex.Unlock()
+	t.Cleanup(func() { sourceCopies = last })
+
+	pruneSourceCopies([]sourceJob{{kind: SourceSubscription, source: config.Source{Url: "http://kept"}}})
+	if _, ok := sourceCopies["http://removed"]; ok || len(sourceCopies) != 1 {
+		t.Fatalf("copies after prune: %v", sourceCopies)
+	}
+}
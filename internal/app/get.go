@@ -1,65 +1,56 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/qiuchao/proxypool/pkg/proxy"
-	"github.com/qiuchao/proxypoolCheck/config"
 	"gopkg.in/yaml.v2"
 	"log"
-	"strings"
 	"strconv"
+	"strings"
 )
 
-func getAllProxies() (proxy.ProxyList, error) {
+// Fetch errors of each source are recorded in the report, it fails only when no source gives a proxy
+func getAllProxies(ctx context.Context, report *RunReport, infos proxyInfos, jobs []sourceJob) (proxy.ProxyList, error) {
 	var proxylist proxy.ProxyList
 	var errs []error // collect errors
 	log.Printf("[Andy] Get all proxies")
-	
-	for _, url := range config.Config.ClashConfigUrl {
-		proxyList, err := getClashConfigProxies(url)
 
-		if err != nil {
-			log.Printf("Error when fetch %s: %s\n", url, err.Error())
-			errs = append(errs, err)
-			continue
-		}
-		for _, value := range proxyList {
-			proxylist = append(proxylist, value)
-		}
-		log.Printf("[Andy] Get proxies from clash config, url: %s\tproxies: %d", url, len(proxyList))
+	results := fetchAll(ctx, jobs)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
+	for _, r := range results {
+		url := r.source.Url
+		report.AddSource(SourceResult{
+			Kind:       r.kind,
+			Url:        url,
+			Proxies:    len(r.proxies),
+			Attempts:   r.attempts,
+			Fallback:   r.fallback,
+			Unchanged:  r.unchanged,
+			DurationMs: r.duration.Milliseconds(),
+		}, r.err)
 
-	for _, value := range config.Config.ServerUrl {
-		url := formatURL(value)
-		pjson, err := getProxies(url)
-
-		if err != nil {
-			log.Printf("Error when fetch %s: %s\n", url, err.Error())
-			errs = append(errs, err)
+		if r.err != nil {
+			log.Printf("Error when fetch %s: %s\n", url, r.err.Error())
+			errs = append(errs, r.err)
+			report.AddError(StageFetch, errors.New(maskSource(fmt.Sprintf("%s: %s", url, r.err), url)))
+		}
+		if len(r.proxies) == 0 {
 			continue
 		}
-
-		var count = 0
-		for i, p := range pjson {
-			if i == 0 || len(p) < 2 {
-				continue
-			}
-			p = p[2:] // remove "- "
-
-			if pp, ok := convert2Proxy(p); ok {
-				if i == 1 && pp.BaseInfo().Name == "NULL" {
-					log.Println("no proxy on " + url)
-					errs = append(errs, errors.New("no proxy on "+url))
-					continue
-				}
-				// name := strings.Replace(pp.BaseInfo().Name, " |", "_", 1)
-				// pp.SetName(name)
-				proxylist = append(proxylist, pp)
-				count = count + 1
-			}
+		infos.setSource(r.proxies, r.kind, url)
+		for _, value := range r.proxies {
+			proxylist = append(proxylist, value)
+		}
+		if r.unchanged {
+			log.Printf("[Andy] Source unchanged, url: %s\tproxies: %d", url, len(r.proxies))
+		} else {
+			log.Printf("[Andy] Get proxies from %s, url: %s\tproxies: %d", r.kind, url, len(r.proxies))
 		}
-		log.Printf("[Andy] Get proxies from proxypool, url: %s\tproxies: %d", url, count)
 	}
 
 	if proxylist == nil {
@@ -82,7 +73,7 @@ func getAllProxies() (proxy.ProxyList, error) {
 			if name == "" {
 				name = "unknown"
 			}
-			newName := name + strconv.Itoa(c + 1)
+			newName := name + strconv.Itoa(c+1)
 			// log.Printf("[Andy] Change proxy name form %s to %s", name, newName)
 			name = newName
 		}
@@ -91,6 +82,15 @@ func getAllProxies() (proxy.ProxyList, error) {
 	return proxylist, nil
 }
 
+// sourceWeights maps the source urls to their weights
+func sourceWeights() map[string]int {
+	weights := make(map[string]int)
+	for _, job := range sourceJobs() {
+		weights[job.source.Url] = job.source.Weight
+	}
+	return weights
+}
+
 func formatURL(value string) string {
 	url := "http://127.0.0.1:8080"
 	if value != "http://127.0.0.1:8080" {
@@ -110,31 +110,37 @@ type ClashConfig struct {
 	Proxy []map[string]interface{} `yaml:"proxies"`
 }
 
-// get proxy strings from url
-func getProxies(url string) ([]string, error) {
-	fileData, err := config.ReadFile(url)
-	if err != nil {
-		return nil, err
-	}
-	proxyJson := strings.Split(string(fileData), "\n")
-
+// get proxies from the output of a proxypool server, a json proxy on each line
+func parseServerProxies(data []byte) (proxy.ProxyList, error) {
+	proxyJson := strings.Split(string(data), "\n")
 	if len(proxyJson) < 2 {
-		return nil, errors.New("no proxy on " + url)
+		return nil, errors.New("no proxy")
 	}
-	return proxyJson, nil
-}
+	proxyList := make(proxy.ProxyList, 0)
+	for i, p := range proxyJson {
+		if i == 0 || len(p) < 2 {
+			continue
+		}
+		p = p[2:] // remove "- "
 
-func getClashConfigProxies(path string) (proxy.ProxyList, error) {
-	fileData, err := config.ReadFile(path)
-	if err != nil {
-		return nil, err
+		if pp, ok := convert2Proxy(p); ok {
+			if i == 1 && pp.BaseInfo().Name == "NULL" {
+				continue
+			}
+			// name := strings.Replace(pp.BaseInfo().Name, " |", "_", 1)
+			// pp.SetName(name)
+			proxyList = append(proxyList, pp)
+		}
 	}
+	return proxyList, nil
+}
 
+func parseClashConfigProxies(fileData []byte) (proxy.ProxyList, error) {
 	var cf ClashConfig
-	err = yaml.Unmarshal(fileData, &cf)
+	err := yaml.Unmarshal(fileData, &cf)
 	if err != nil {
 		return nil, err
-	} 
+	}
 
 	proxyList := make(proxy.ProxyList, 0)
 	for _, pjson := range cf.Proxy {
@@ -147,12 +153,32 @@ func getClashConfigProxies(path string) (proxy.ProxyList, error) {
 	return proxyList, nil
 }
 
+// parseLocalFile reads a clash config, a json list of clash proxies, or a list of share links
+func parseLocalFile(data []byte) (proxy.ProxyList, error) {
+	if proxyList, err := parseClashConfigProxies(data); err == nil && len(proxyList) > 0 {
+		return proxyList, nil
+	}
+	var list []map[string]interface{}
+	if err := json.Unmarshal(data, &list); err == nil {
+		proxyList := make(proxy.ProxyList, 0)
+		for _, pjson := range list {
+			p, err := parseProxyFromClashProxy(pjson)
+			if err == nil && p != nil {
+				proxyList = append(proxyList, p)
+			}
+		}
+		return proxyList, nil
+	}
+	return parseSubscription(string(data)), nil
+}
+
 func parseProxyFromClashProxy(p map[string]interface{}) (_p proxy.Proxy, err error) {
 	pjson, err := json.Marshal(p)
 	if err != nil {
 		return nil, err
 	}
-	switch p["type"].(string) {
+	proxyType, _ := p["type"].(string)
+	switch proxyType {
 	case "ss":
 		var _p proxy.Shadowsocks
 		err := json.Unmarshal(pjson, &_p)
@@ -181,6 +207,30 @@ func parseProxyFromClashProxy(p map[string]interface{}) (_p proxy.Proxy, err err
 			return nil, err
 		}
 		return &_p, nil
+	case "vless":
+		var _p Vless
+		err := json.Unmarshal(pjson, &_p)
+		if err != nil {
+			return nil, err
+		}
+		_p.keepSNI()
+		return &_p, nil
+	case "hysteria2":
+		var _p Hysteria2
+		err := json.Unmarshal(pjson, &_p)
+		if err != nil {
+			return nil, err
+		}
+		_p.keepSNI()
+		return &_p, nil
+	case "tuic":
+		var _p Tuic
+		err := json.Unmarshal(pjson, &_p)
+		if err != nil {
+			return nil, err
+		}
+		_p.keepSNI()
+		return &_p, nil
 	}
 	return nil, errors.New("clash json parse failed")
 }
@@ -192,9 +242,13 @@ func convert2Proxy(pjson string) (proxy.Proxy, bool) {
 	if err != nil {
 		return nil, false
 	}
-	jsnMap := f.(interface{}).(map[string]interface{})
+	jsnMap, ok := f.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	proxyType, _ := jsnMap["type"].(string)
 
-	switch jsnMap["type"].(string) {
+	switch proxyType {
 	case "ss":
 		var p proxy.Shadowsocks
 		err := json.Unmarshal([]byte(pjson), &p)
@@ -223,6 +277,30 @@ func convert2Proxy(pjson string) (proxy.Proxy, bool) {
 			return nil, false
 		}
 		return &p, true
+	case "vless":
+		var p Vless
+		err := json.Unmarshal([]byte(pjson), &p)
+		if err != nil {
+			return nil, false
+		}
+		p.keepSNI()
+		return &p, true
+	case "hysteria2":
+		var p Hysteria2
+		err := json.Unmarshal([]byte(pjson), &p)
+		if err != nil {
+			return nil, false
+		}
+		p.keepSNI()
+		return &p, true
+	case "tuic":
+		var p Tuic
+		err := json.Unmarshal([]byte(pjson), &p)
+		if err != nil {
+			return nil, false
+		}
+		p.keepSNI()
+		return &p, true
 	}
 	return nil, false
 }
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+)
+
+var ErrorNotHysteria2Link = errors.New("not a correct hysteria2 link")
+
+// Hysteria2 is a type of proxy, fields follow Clash.Meta
+type Hysteria2 struct {
+	proxy.Base
+	Password       string   `yaml:"password" json:"password"`
+	Up             string   `yaml:"up,omitempty" json:"up,omitempty"`
+	Down           string   `yaml:"down,omitempty" json:"down,omitempty"`
+	Obfs           string   `yaml:"obfs,omitempty" json:"obfs,omitempty"`
+	ObfsPassword   string   `yaml:"obfs-password,omitempty" json:"obfs-password,omitempty"`
+	SNI            string   `yaml:"sni,omitempty" json:"sni,omitempty"`
+	SkipCertVerify bool     `yaml:"skip-cert-verify,omitempty" json:"skip-cert-verify,omitempty"`
+	ALPN           []string `yaml:"alpn,omitempty" json:"alpn,omitempty"`
+}
+
+/**
+  - name: "hysteria2"
+    type: hysteria2
+    server: server
+    port: 443
+    password: yourpassword
+    # sni: example.com
+    # obfs: salamander
+    # obfs-password: yourpassword
+    # skip-cert-verify: true
+*/
+
+func (h Hysteria2) Identifier() string {
+	return net.JoinHostPort(h.Server, strconv.Itoa(h.Port)) + h.Password
+}
+
+func (h Hysteria2) String() string {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (h Hysteria2) ToClash() string {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return ""
+	}
+	return "- " + string(data)
+}
+
+func (h Hysteria2) ToSurge() string {
+	return ""
+}
+
+// Clone copies the alpn too
+func (h Hysteria2) Clone() proxy.Proxy {
+	h.ALPN = slices.Clone(h.ALPN)
+	return &h
+}
+
+// https://v2.hysteria.network/docs/developers/URI-Scheme/
+func (h Hysteria2) Link() (link string) {
+	query := url.Values{}
+	if h.SNI != "" {
+		query.Set("sni", h.SNI)
+	}
+	if h.SkipCertVerify {
+		query.Set("insecure", "1")
+	}
+	if h.Obfs != "" {
+		query.Set("obfs", h.Obfs)
+		query.Set("obfs-password", h.ObfsPassword)
+	}
+
+	uri := url.URL{
+		Scheme:   "hysteria2",
+		User:     url.User(h.Password),
+		Host:     net.JoinHostPort(h.Server, strconv.Itoa(h.Port)),
+		Path:     "/",
+		RawQuery: query.Encode(),
+		Fragment: h.Name,
+	}
+	return uri.String()
+}
+
+// keepSNI keeps the domain for tls, the server will be replaced by ip later
+func (h *Hysteria2) keepSNI() {
+	if h.SNI == "" && net.ParseIP(h.Server) == nil {
+		h.SNI = h.Server
+	}
+}
+
+func ParseHysteria2Link(link string) (*Hysteria2, error) {
+	if !strings.HasPrefix(link, "hysteria2://") && !strings.HasPrefix(link, "hy2://") {
+		return nil, ErrorNotHysteria2Link
+	}
+
+	l, ok := parseQUICLink(link, "hysteria2", 443)
+	if !ok {
+		return nil, ErrorNotHysteria2Link
+	}
+	password := l.user.Username()
+	if pass, ok := l.user.Password(); ok {
+		password = password + ":" + pass
+	}
+	if password == "" {
+		return nil, ErrorNotHysteria2Link
+	}
+
+	h := &Hysteria2{
+		Base:           l.Base,
+		Password:       password,
+		Obfs:           l.query.Get("obfs"),
+		ObfsPassword:   l.query.Get("obfs-password"),
+		SNI:            l.sni,
+		SkipCertVerify: l.query.Get("insecure") == "1",
+	}
+	h.keepSNI()
+	return h, nil
+}
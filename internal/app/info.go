@@ -0,0 +1,216 @@
+package app
+
+import (
+	"sort"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/healthcheck"
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// proxyInfos collects the metadata of proxies during a run. It is keyed by the proxy itself,
+// because Identifier() changes when the server is replaced by the resolved ip
+type proxyInfos map[proxy.Proxy]*cache.ProxyInfo
+
+// newProxyInfos starts with the saved infos of the last result, they are checked again in this run
+func newProxyInfos(lastProxies proxy.ProxyList, saved map[string]cache.ProxyInfo) proxyInfos {
+	infos := make(proxyInfos)
+	for _, p := range lastProxies {
+		if info, ok := saved[p.Identifier()]; ok {
+			infos[p] = &info
+		}
+	}
+	return infos
+}
+
+func (infos proxyInfos) get(p proxy.Proxy) *cache.ProxyInfo {
+	info, ok := infos[p]
+	if !ok {
+		info = &cache.ProxyInfo{Bandwidth: -1, TTFBMs: -1}
+		infos[p] = info
+	}
+	return info
+}
+
+// setSource tags the proxies with the source they are fetched from and their names in it
+func (infos proxyInfos) setSource(proxies proxy.ProxyList, kind string, url string) {
+	for _, p := range proxies {
+		info := infos.get(p)
+		info.SourceKind = kind
+		info.Source = url
+		info.Name = p.BaseInfo().Name
+	}
+}
+
+// deduplicate does Derive().Deduplication() on the last result and the fetched proxies, and keeps their sources.
+// Of the same Identifier(), the one from the source with the highest weight is kept, the last result first on a tie.
+// Its source is updated to the fetched one, so a proxy is tagged with the source still giving it
+func (infos proxyInfos) deduplicate(last proxy.ProxyList, fetched proxy.ProxyList, weights map[string]int) proxy.ProxyList {
+	fetched = infos.derive(fetched)
+	infos.sortByWeight(fetched, weights)
+	sources := make(map[string]*cache.ProxyInfo)
+	for _, p := range fetched {
+		if _, ok := sources[p.Identifier()]; ok {
+			continue
+		}
+		if info, ok := infos[p]; ok && info.Source != "" {
+			sources[p.Identifier()] = info
+		}
+	}
+
+	all := append(infos.derive(last), fetched...)
+	infos.sortByWeight(all, weights)
+	result := all.Deduplication()
+	for _, p := range result {
+		if s, ok := sources[p.Identifier()]; ok {
+			info := infos.get(p)
+			info.Source = s.Source
+			info.SourceKind = s.SourceKind
+			info.Name = s.Name
+		}
+	}
+	return result
+}
+
+// derive is proxy.ProxyList.Derive() without dedup, ss and ssr are converted to each other. The converted ones get the infos of the origin
+func (infos proxyInfos) derive(proxies proxy.ProxyList) proxy.ProxyList {
+	derived := make(proxy.ProxyList, 0, len(proxies)*2)
+	derived = append(derived, proxies...)
+	for _, p := range proxies {
+		var d proxy.Proxy
+		var err error
+		switch p.TypeName() {
+		case "ss":
+			d, err = proxy.Convert2SSR(p)
+		case "ssr":
+			d, err = proxy.Convert2SS(p)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if info, ok := infos[p]; ok {
+			copied := *info
+			infos[d] = &copied
+		}
+		derived = append(derived, d)
+	}
+	return derived
+}
+
+// sortByWeight keeps the order of the same weight
+func (infos proxyInfos) sortByWeight(proxies proxy.ProxyList, weights map[string]int) {
+	weight := func(p proxy.Proxy) int {
+		if info, ok := infos[p]; ok {
+			return weights[info.Source]
+		}
+		return 0
+	}
+	sort.SliceStable(proxies, func(i, j int) bool {
+		return weight(proxies[i]) > weight(proxies[j])
+	})
+}
+
+// setDelays reads the healthcheck delays, probeDelays also keeps its result in healthcheck.ProxyStats
+func (infos proxyInfos) setDelays(proxies proxy.ProxyList) {
+	for _, p := range proxies {
+		if ps, ok := healthcheck.ProxyStats.Find(p); ok {
+			infos.get(p).DelayMs = ps.Delay.Milliseconds()
+		}
+	}
+}
+
+// setResults matches the third part speed test results by name, so it must be called before renaming
+func (infos proxyInfos) setResults(proxies proxy.ProxyList, results []Result) {
+	byName := make(map[string]Result, len(results))
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	for _, p := range proxies {
+		r, ok := byName[p.BaseInfo().Name]
+		if !ok {
+			continue
+		}
+		info := infos.get(p)
+		info.Bandwidth = r.Bandwidth
+		info.TTFBMs = -1
+		if r.TTFB > 0 {
+			info.TTFBMs = r.TTFB.Milliseconds()
+		}
+	}
+}
+
+// byIdentifier is what goes to cache with the final proxies
+func (infos proxyInfos) byIdentifier(proxies proxy.ProxyList) map[string]cache.ProxyInfo {
+	result := make(map[string]cache.ProxyInfo, len(proxies))
+	for _, p := range proxies {
+		result[p.Identifier()] = *infos.get(p)
+	}
+	return result
+}
+
+// updateSourceStats counts the checked proxies of each source fetched in this run, the history is kept in cache
+func updateSourceStats(report *RunReport, infos proxyInfos, proxies proxy.ProxyList) {
+	survived := make(map[string]int)
+	for _, p := range proxies {
+		if info, ok := infos[p]; ok && info.Source != "" {
+			survived[info.Source]++
+		}
+	}
+	report.SetSurvived(survived)
+
+	// sources removed from config are forgotten, the ones not fetched in this run are kept
+	last := cache.Current().SourceStats
+	stats := make(map[string]cache.SourceStat, len(last))
+	for _, job := range sourceJobs() {
+		if stat, ok := last[job.source.Url]; ok {
+			stats[job.source.Url] = stat
+		}
+	}
+	now := config.Now().Format("2006-01-02 15:04:05")
+	for _, s := range report.Sources {
+		stat := last[s.url]
+		stat.Kind = s.Kind
+		stat.Runs++
+		stat.Fetched += s.Proxies
+		stat.Survived += survived[s.url]
+		stat.LastFetched = s.Proxies
+		stat.LastSurvived = survived[s.url]
+		stat.LastError = s.Error
+		stat.LastRunTime = now
+		stats[s.url] = stat
+	}
+	cache.Update(func(s *cache.State) {
+		s.SourceStats = stats
+	})
+}
+
+// speedResults copies the speed test results of the proxies out of healthcheck.ProxyStats, only the runs use that one.
+// It is empty, not nil, without a speed test
+func speedResults(proxies proxy.ProxyList) map[string]float64 {
+	speeds := make(map[string]float64)
+	if !healthcheck.SpeedExist {
+		return speeds
+	}
+	for _, p := range proxies {
+		if ps, ok := healthcheck.ProxyStats.Find(p); ok {
+			speeds[p.Identifier()] = ps.Speed
+		}
+	}
+	return speeds
+}
+
+// keep the probe delay like CleanBadProxiesWithGrpool does for the core supported proxies
+func updateProxyStatsDelay(p proxy.Proxy, delay time.Duration) {
+	if ps, ok := healthcheck.ProxyStats.Find(p); ok {
+		ps.UpdatePSDelay(delay)
+	} else {
+		healthcheck.ProxyStats = append(healthcheck.ProxyStats, healthcheck.Stat{
+			Id:    p.Identifier(),
+			Delay: delay,
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+)
+
+// hopPorts is the port hopping of a link, like host:443,20000-30000 or host:20000-30000. url.Parse rejects it
+var hopPorts = regexp.MustCompile(`^([^/?#]*@)?(\[[^\]/?#]*\]|[^:/?#\[\]]*):(\d+)[-,][\d,-]*`)
+
+// quicLink is what the hysteria2 and tuic links share: scheme://auth@server:port/?sni=...#name
+type quicLink struct {
+	proxy.Base
+	user  *url.Userinfo // nil without auth
+	query url.Values
+	sni   string
+}
+
+// parseQUICLink parses link without checking the scheme. A port hopping link uses the first port,
+// defaultPort is used without a port, 0 when the port must be set
+func parseQUICLink(link string, typeName string, defaultPort int) (*quicLink, bool) {
+	if scheme, rest, ok := strings.Cut(link, "://"); ok {
+		link = scheme + "://" + hopPorts.ReplaceAllString(rest, "${1}${2}:${3}")
+	}
+	uri, err := url.Parse(link)
+	if err != nil {
+		return nil, false
+	}
+	port, _ := strconv.Atoi(uri.Port())
+	if uri.Port() == "" {
+		port = defaultPort
+	}
+	if port == 0 {
+		return nil, false
+	}
+	query := uri.Query()
+	return &quicLink{
+		Base: proxy.Base{
+			Name:   uri.Fragment,
+			Server: uri.Hostname(),
+			Port:   port,
+			Type:   typeName,
+			UDP:    true,
+		},
+		user:  uri.User,
+		query: query,
+		sni:   query.Get("sni"),
+	}, true
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/healthcheck"
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"golang.org/x/crypto/blake2b"
+)
+
+// The bundled clash core can only dial these types. Others (vless, hysteria2, tuic) are checked by probing the server
+func isCoreSupported(p proxy.Proxy) bool {
+	switch p.TypeName() {
+	case "ss", "ssr", "vmess", "trojan":
+		return true
+	}
+	return false
+}
+
+// Split proxies into the ones the clash core can test and the ones need a probe
+func splitByCoreSupport(proxies proxy.ProxyList) (supported proxy.ProxyList, others proxy.ProxyList) {
+	for _, p := range proxies {
+		if isCoreSupported(p) {
+			supported = append(supported, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	return
+}
+
+// Healthcheck all proxies, the result keeps the order of core supported ones first.
+// When ctx is done, the proxies not checked yet are dropped and ctx.Err() is returned
+func healthCheck(ctx context.Context, proxies proxy.ProxyList) (proxy.ProxyList, error) {
+	supported, others := splitByCoreSupport(proxies)
+	result := inBatches(ctx, supported, healthcheck.DelayConn, healthcheck.CleanBadProxiesWithGrpool)
+	delays := probeDelays(ctx, others)
+	for _, p := range others {
+		if delay, ok := delays[p.Identifier()]; ok {
+			updateProxyStatsDelay(p, delay)
+			result = append(result, p)
+		}
+	}
+	return result, ctx.Err()
+}
+
+// inBatches runs a check of the proxypool lib on batches of size proxies, it can not be stopped inside a batch.
+// The proxies after ctx is done are not checked
+func inBatches(ctx context.Context, proxies proxy.ProxyList, size int, check func([]proxy.Proxy) []proxy.Proxy) proxy.ProxyList {
+	if size <= 0 {
+		size = 1
+	}
+	result := make(proxy.ProxyList, 0, len(proxies))
+	for i := 0; i < len(proxies) && ctx.Err() == nil; i += size {
+		end := i + size
+		if end > len(proxies) {
+			end = len(proxies)
+		}
+		result = append(result, check(proxies[i:end])...)
+	}
+	return result
+}
+
+// probeDelays probes proxies concurrently and returns the delay of reachable ones by Identifier()
+func probeDelays(ctx context.Context, proxies proxy.ProxyList) map[string]time.Duration {
+	delays := make(map[string]time.Duration)
+	if len(proxies) == 0 {
+		return delays
+	}
+	conn := healthcheck.DelayConn
+	if conn <= 0 {
+		conn = 1
+	}
+
+	m := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, conn)
+	for _, p := range proxies {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p proxy.Proxy) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			delay, err := probeProxy(ctx, p, healthcheck.DelayTimeout)
+			if err != nil {
+				return
+			}
+			m.Lock()
+			delays[p.Identifier()] = delay
+			m.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return delays
+}
+
+// probeProxy checks that the server is listening. vless is over tcp, hysteria2 and tuic are over quic
+func probeProxy(ctx context.Context, p proxy.Proxy, timeout time.Duration) (time.Duration, error) {
+	return probeAddr(ctx, p, net.JoinHostPort(p.BaseInfo().Server, strconv.Itoa(p.BaseInfo().Port)), timeout)
+}
+
+// probeAddr is probeProxy on another address of the server
+func probeAddr(ctx context.Context, p proxy.Proxy, addr string, timeout time.Duration) (time.Duration, error) {
+	switch p.TypeName() {
+	case "hysteria2":
+		if h, ok := p.(*Hysteria2); ok && h.Obfs != "" {
+			// obfuscated quic never answers a plain packet, the probe is obfuscated too
+			if h.Obfs != "salamander" {
+				return 0, fmt.Errorf("unknown obfs %s", h.Obfs)
+			}
+			return probeQUIC(ctx, addr, timeout, []byte(h.ObfsPassword))
+		}
+		return probeQUIC(ctx, addr, timeout, nil)
+	case "tuic":
+		return probeQUIC(ctx, addr, timeout, nil)
+	default:
+		start := time.Now()
+		conn, err := dial(ctx, "tcp", addr, timeout)
+		if err != nil {
+			return 0, err
+		}
+		conn.Close()
+		return time.Since(start), nil
+	}
+}
+
+// probeQUIC sends a quic initial packet with a reserved version, any quic server must answer with version negotiation (RFC 9000 6.1).
+// obfs is the salamander password of hysteria2, nil for plain quic
+func probeQUIC(ctx context.Context, addr string, timeout time.Duration, obfs []byte) (time.Duration, error) {
+	conn, err := dial(ctx, "udp", addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// long header | version 0x?a?a?a?a | dcid len + dcid | scid len + scid | padding to 1200 bytes
+	packet := make([]byte, 1200)
+	packet[0] = 0xc0
+	copy(packet[1:5], []byte{0x1a, 0x2a, 0x3a, 0x4a})
+	packet[5] = 8
+	_, _ = rand.Read(packet[6:14])
+	packet[14] = 8
+	_, _ = rand.Read(packet[15:23])
+	if obfs != nil {
+		salt := make([]byte, salamanderSalt)
+		_, _ = rand.Read(salt)
+		packet = salamander(obfs, salt, packet)
+	}
+
+	start := time.Now()
+	_ = conn.SetDeadline(start.Add(timeout))
+	if _, err = conn.Write(packet); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	buf = buf[:n]
+	if obfs != nil {
+		if n < salamanderSalt {
+			return 0, errors.New("not a salamander packet")
+		}
+		buf = salamander(obfs, buf[:salamanderSalt], buf[salamanderSalt:])[salamanderSalt:]
+	}
+	if len(buf) < 5 || buf[0]&0x80 == 0 || !bytes.Equal(buf[1:5], []byte{0, 0, 0, 0}) {
+		return 0, errors.New("not a quic version negotiation packet")
+	}
+	return time.Since(start), nil
+}
+
+const salamanderSalt = 8
+
+// salamander is the obfs of hysteria2: the salt, then the packet xor blake2b-256(password + salt).
+// It is the same both ways
+func salamander(password []byte, salt []byte, packet []byte) []byte {
+	key := blake2b.Sum256(append(append([]byte(nil), password...), salt...))
+	out := make([]byte, len(salt)+len(packet))
+	copy(out, salt)
+	for i, b := range packet {
+		out[len(salt)+i] = b ^ key[i%len(key)]
+	}
+	return out
+}
+
+// dial is net.DialTimeout stopped by ctx. The deadline of reading is set by the callers, a done ctx closes the conn
+func dial(ctx context.Context, network string, addr string, timeout time.Duration) (net.Conn, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	return &stopConn{Conn: conn, stop: stop}, nil
+}
+
+type stopConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *stopConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
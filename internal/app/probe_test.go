@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+)
+
+// quicServer answers every packet with reply, nil for no answer
+func quicServer(t *testing.T, reply func(packet []byte) []byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if r := reply(buf[:n]); r != nil {
+				_, _ = conn.WriteTo(r, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestProbeQUIC(t *testing.T) {
+	negotiation := func(packet []byte) []byte {
+		if len(packet) != 1200 || packet[0]&0xc0 != 0xc0 {
+			return nil
+		}
+		// long header, version 0, the connection ids swapped
+		return append([]byte{0x80, 0, 0, 0, 0}, packet[14:23]...)
+	}
+	tests := []struct {
+		name  string
+		reply func([]byte) []byte
+		ok    bool
+	}{
+		{"version negotiation", negotiation, true},
+		{"not quic", func([]byte) []byte { return []byte("hello") }, false},
+		{"short header", func([]byte) []byte { return []byte{0x40, 0, 0, 0, 0} }, false},
+		{"no answer", func([]byte) []byte { return nil }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := quicServer(t, tt.reply)
+			_, err := probeQUIC(context.Background(), addr, 300*time.Millisecond, nil)
+			if (err == nil) != tt.ok {
+				t.Fatalf("probeQUIC() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProbeSalamander(t *testing.T) {
+	password := []byte("secret")
+	// a hysteria2 server with obfs only answers the packets obfuscated with its password
+	obfsServer := func(packet []byte) []byte {
+		if len(packet) != salamanderSalt+1200 {
+			return nil
+		}
+		plain := salamander(password, packet[:salamanderSalt], packet[salamanderSalt:])[salamanderSalt:]
+		if plain[0]&0xc0 != 0xc0 {
+			return nil
+		}
+		reply := append([]byte{0x80, 0, 0, 0, 0}, plain[14:23]...)
+		return salamander(password, []byte("12345678"), reply)
+	}
+	addr := quicServer(t, obfsServer)
+	if _, err := probeQUIC(context.Background(), addr, 300*time.Millisecond, password); err != nil {
+		t.Fatalf("probeQUIC() with the password: %s", err)
+	}
+	if _, err := probeQUIC(context.Background(), addr, 300*time.Millisecond, []byte("wrong")); err == nil {
+		t.Fatal("probeQUIC() passed with a wrong password")
+	}
+
+	// a silent server is down, not alive
+	h := &Hysteria2{Base: proxy.Base{Type: "hysteria2"}, Obfs: "salamander", ObfsPassword: "secret"}
+	silent := quicServer(t, func([]byte) []byte { return nil })
+	if _, err := probeAddr(context.Background(), h, silent, 300*time.Millisecond); err == nil {
+		t.Fatal("probeAddr() passed on a silent server")
+	}
+	if _, err := probeAddr(context.Background(), h, addr, 300*time.Millisecond); err != nil {
+		t.Fatalf("probeAddr() with obfs: %s", err)
+	}
+}
+
+func TestProbeQUICCanceled(t *testing.T) {
+	addr := quicServer(t, func([]byte) []byte { return nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := probeQUIC(ctx, addr, 10*time.Second, nil); err == nil {
+		t.Fatal("probeQUIC() passed after cancel")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("probeQUIC() returned %s after cancel", d)
+	}
+}
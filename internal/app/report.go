@@ -0,0 +1,393 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/metrics"
+)
+
+// Stages of a run
+const (
+	StageFetch              = "fetch"
+	StageRules              = "rules"
+	StageResolve            = "resolve"
+	StageReputation         = "reputation"
+	StageDedup              = "dedup"
+	StageHealthcheck        = "healthcheck"
+	StageSpeedtest          = "speedtest"
+	StageThirdpartSpeedtest = "thirdpart_speedtest"
+	StageMaxCount           = "max_count"
+	StageBaseInfo           = "base_info"
+	StageCache              = "cache"
+)
+
+// StageError is an error happened in one stage of a run
+type StageError struct {
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+// SkippedProxy is a proxy dropped by a run, with the reason
+type SkippedProxy struct {
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	Type   string `json:"type"`
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+// StageStat is the duration and proxy count in/out of one stage. For fetch stage In is the count of sources
+type StageStat struct {
+	Name       string    `json:"name"`
+	StartTime  time.Time `json:"start_time"`
+	DurationMs int64     `json:"duration_ms"`
+	In         int       `json:"in"`
+	Out        int       `json:"out"`
+	Reused     int       `json:"reused,omitempty"` // fresh results reused instead of testing, of the healthcheck stage
+	Done       bool      `json:"done"`
+}
+
+// SourceResult is the fetch result of one source
+type SourceResult struct {
+	Kind       string `json:"kind"` // clash_config, subscription or server
+	Url        string `json:"url"`
+	Proxies    int    `json:"proxies"`
+	Survived   int    `json:"survived"` // proxies passed the checks
+	Attempts   int    `json:"attempts"`
+	Fallback   bool   `json:"fallback,omitempty"`  // proxies are from the last good copy, the fetch failed
+	Unchanged  bool   `json:"unchanged,omitempty"` // the source has not changed, proxies parsed last time are reused
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+
+	url string // Url is masked, this one is the key of the source
+}
+
+// maskSource hides the token of the source url in s
+func maskSource(s string, url string) string {
+	if url == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, url, config.MaskURL(url))
+}
+
+// Kinds of runs
+const (
+	RunFull        = "full"        // fetch the sources and check them all
+	RunFiles       = "files"       // the changed local files
+	RunHealthcheck = "healthcheck" // healthcheck_cron on the cached proxies
+	RunSpeedtest   = "speedtest"   // speedtest_cron on the cached proxies
+)
+
+// Status of a run
+const (
+	RunQueued    = "queued"
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunCanceled  = "canceled" // by /api/runs/:id/cancel or shutdown, what is done is saved
+	RunSkipped   = "skipped"  // in a sleep window
+)
+
+// RunProgress is how far a run has gone, by stages
+type RunProgress struct {
+	Step    int `json:"step"`  // finished stages
+	Steps   int `json:"steps"` // stages expected
+	Percent int `json:"percent"`
+}
+
+// maxSkipped is how many skipped proxies a report keeps, the rest are only counted
+const maxSkipped = 100
+
+// RunReport records what happened in one run
+type RunReport struct {
+	ID         int64          `json:"id"`
+	Kind       string         `json:"kind"`
+	Status     string         `json:"status"`
+	Files      []string       `json:"files,omitempty"` // of a RunFiles run
+	Progress   RunProgress    `json:"progress"`
+	QueuedTime time.Time      `json:"queued_time"`
+	StartTime  time.Time      `json:"start_time"`
+	EndTime    time.Time      `json:"end_time"`
+	DurationMs int64          `json:"duration_ms"`
+	Success    bool           `json:"success"`
+	Stage      string         `json:"stage"` // the running stage, or the last one when finished
+	Stages     []*StageStat   `json:"stages"`
+	Sources    []SourceResult `json:"sources"`
+	Errors     []StageError   `json:"errors"`
+	Skipped    []SkippedProxy `json:"skipped"`       // the first maxSkipped ones
+	SkipCount  map[string]int `json:"skipped_count"` // by stage, of all the skipped ones
+
+	m sync.Mutex
+}
+
+func NewRunReport(kind string) *RunReport {
+	now := time.Now()
+	return &RunReport{
+		Kind:       kind,
+		Status:     RunQueued,
+		QueuedTime: now,
+		StartTime:  now,
+		Stages:     make([]*StageStat, 0),
+		Sources:    make([]SourceResult, 0),
+		Errors:     make([]StageError, 0),
+		Skipped:    make([]SkippedProxy, 0),
+		SkipCount:  make(map[string]int),
+	}
+}
+
+// Start marks the run as running, steps is the count of stages expected
+func (r *RunReport) Start(steps int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.Status = RunRunning
+	r.StartTime = time.Now()
+	r.Progress.Steps = steps
+}
+
+func (r *RunReport) SetStatus(status string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.Status = status
+}
+
+func (r *RunReport) GetStatus() string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.Status
+}
+
+func (r *RunReport) SetFiles(files []string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.Files = append([]string(nil), files...)
+}
+
+// StartStage marks the stage as running with in proxies, errors without a stage are put in it
+func (r *RunReport) StartStage(stage string, in int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.endStage(-1)
+	r.Stage = stage
+	r.Stages = append(r.Stages, &StageStat{
+		Name:      stage,
+		StartTime: time.Now(),
+		In:        in,
+	})
+}
+
+// EndStage finishes the running stage with out proxies left
+func (r *RunReport) EndStage(out int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.endStage(out)
+}
+
+// out < 0 means the stage was interrupted
+func (r *RunReport) endStage(out int) {
+	if len(r.Stages) == 0 {
+		return
+	}
+	s := r.Stages[len(r.Stages)-1]
+	if s.Done {
+		return
+	}
+	s.DurationMs = time.Since(s.StartTime).Milliseconds()
+	if out >= 0 {
+		s.Out = out
+		s.Done = true
+	}
+}
+
+// SetReused records how many proxies of the running stage are not tested, their last results are fresh
+func (r *RunReport) SetReused(n int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if len(r.Stages) > 0 {
+		r.Stages[len(r.Stages)-1].Reused = n
+	}
+}
+
+// AddSource records the fetch result of a source, the url is masked
+func (r *RunReport) AddSource(result SourceResult, err error) {
+	result.url = result.Url
+	result.Url = config.MaskURL(result.url)
+	if err != nil {
+		result.Error = maskSource(err.Error(), result.url)
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.Sources = append(r.Sources, result)
+}
+
+// SetSurvived records how many proxies of each source passed the checks
+func (r *RunReport) SetSurvived(survived map[string]int) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for i := range r.Sources {
+		r.Sources[i].Survived = survived[r.Sources[i].url]
+	}
+}
+
+// AddError records an error of the stage and goes on
+func (r *RunReport) AddError(stage string, err error) {
+	if err == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if stage == "" {
+		stage = r.Stage
+	}
+	r.Errors = append(r.Errors, StageError{Stage: stage, Error: err.Error()})
+}
+
+// Skip records a proxy dropped by the stage
+func (r *RunReport) Skip(p proxy.Proxy, stage string, reason string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.SkipCount[stage]++
+	if len(r.Skipped) >= maxSkipped {
+		return
+	}
+	r.Skipped = append(r.Skipped, SkippedProxy{
+		Name:   p.BaseInfo().Name,
+		Server: p.BaseInfo().Server,
+		Type:   p.TypeName(),
+		Stage:  stage,
+		Reason: reason,
+	})
+}
+
+// Finish ends the run, err is the error which made the whole run fail
+func (r *RunReport) Finish(err error) {
+	r.AddError("", err)
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.endStage(-1)
+	r.EndTime = time.Now()
+	r.DurationMs = r.EndTime.Sub(r.StartTime).Milliseconds()
+	r.Success = err == nil
+	if r.Status == RunSkipped {
+		return
+	}
+	switch {
+	case err == nil:
+		r.Status = RunSucceeded
+	case errors.Is(err, context.Canceled):
+		r.Status = RunCanceled
+	default:
+		r.Status = RunFailed
+	}
+}
+
+// MarshalJSON locks the report, so a running one can be read
+func (r *RunReport) MarshalJSON() ([]byte, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.updateProgress()
+	type report RunReport
+	return json.Marshal((*report)(r))
+}
+
+func (r *RunReport) updateProgress() {
+	p := &r.Progress
+	p.Step = 0
+	for _, s := range r.Stages {
+		if s.Done {
+			p.Step++
+		}
+	}
+	if p.Step > p.Steps {
+		p.Steps = p.Step // a stage not expected, like the speed test turned on by a reload
+	}
+	switch {
+	case r.Status == RunSucceeded || r.Status == RunFailed || r.Status == RunCanceled || r.Status == RunSkipped:
+		p.Percent = 100
+	case p.Steps > 0:
+		p.Percent = p.Step * 100 / p.Steps
+		if p.Percent >= 100 {
+			p.Percent = 99
+		}
+	}
+}
+
+// expectedStages is the count of stages a run of the kind goes through with the current config
+func expectedStages(kind string) int {
+	switch kind {
+	case RunHealthcheck:
+		return 2 // healthcheck, cache
+	case RunSpeedtest:
+		return 3 // thirdpart_speedtest, base_info, cache
+	}
+	// fetch, rules, resolve, reputation, dedup, healthcheck, max_count, base_info, cache
+	n := 9
+	if config.Get().SpeedTest {
+		n++
+	}
+	if config.Get().ThirdpartSpeedtest && config.Get().SpeedtestCron == "" {
+		n++
+	}
+	return n
+}
+
+// export a finished report to prometheus
+func observeReport(r *RunReport) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	metrics.ObserveRun(r.EndTime.Sub(r.StartTime), r.Success)
+	for _, s := range r.Stages {
+		metrics.ObserveStage(s.Name, time.Duration(s.DurationMs)*time.Millisecond, s.In, s.Out)
+	}
+	if len(r.Sources) > 0 {
+		metrics.ResetSources()
+		for _, s := range r.Sources {
+			metrics.SetSource(s.Kind, s.Url, s.Error == "", s.Proxies)
+		}
+	}
+}
+
+var (
+	reports     = make([]*RunReport, 0)
+	reportMutex sync.RWMutex
+)
+
+// keep the last run_history reports
+func addReport(r *RunReport) {
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	reports = append(reports, r)
+	if n := config.Get().RunHistory; n > 0 && len(reports) > n {
+		reports = reports[len(reports)-n:]
+	}
+}
+
+// LastReport returns the report of the last finished run, nil if no run has finished
+func LastReport() *RunReport {
+	reportMutex.RLock()
+	defer reportMutex.RUnlock()
+	if len(reports) == 0 {
+		return nil
+	}
+	return reports[len(reports)-1]
+}
+
+// Reports returns at most limit finished reports, the newest first. limit <= 0 for all
+func Reports(limit int) []*RunReport {
+	reportMutex.RLock()
+	defer reportMutex.RUnlock()
+	result := make([]*RunReport, 0, len(reports))
+	for i := len(reports) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, reports[i])
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReportMasksSources(t *testing.T) {
+	const url = "https://a.com/sub?token=s3cret"
+	r := NewRunReport(RunFull)
+	r.AddSource(SourceResult{Kind: "subscription", Url: url}, errors.New(`Get "`+url+`": EOF`))
+	r.SetSurvived(map[string]int{url: 3})
+
+	s := r.Sources[0]
+	if s.Url != "https://a.com/sub?token=***" || strings.Contains(s.Error, "s3cret") {
+		t.Errorf("source not masked: %+v", s)
+	}
+	if s.Survived != 3 {
+		t.Errorf("survived = %d, want 3", s.Survived)
+	}
+}
+
+func TestReportSkippedBounded(t *testing.T) {
+	p, err := ParseVlessLink("vless://uuid@1.2.3.4:443#a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunReport(RunFull)
+	for i := 0; i < maxSkipped+50; i++ {
+		r.Skip(p, StageRules, "denied")
+	}
+	r.Skip(p, StageResolve, "no such host")
+	if len(r.Skipped) != maxSkipped {
+		t.Errorf("kept %d skipped, want %d", len(r.Skipped), maxSkipped)
+	}
+	if r.SkipCount[StageRules] != maxSkipped+50 || r.SkipCount[StageResolve] != 1 {
+		t.Errorf("skipped_count = %v", r.SkipCount)
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+	"github.com/qiuchao/proxypoolCheck/internal/metrics"
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
+)
+
+// reputationFilter drops the banned proxies and the ones in quarantine, they are not tested in this run
+func reputationFilter(report *RunReport, proxies proxy.ProxyList, now time.Time) proxy.ProxyList {
+	state := cache.Current()
+	result := make(proxy.ProxyList, 0, len(proxies))
+	for _, p := range proxies {
+		id := p.Identifier()
+		switch state.Overrides[id] {
+		case reputation.Ban:
+			report.Skip(p, StageReputation, "banned")
+			continue
+		case reputation.Pin:
+			result = append(result, p)
+			continue
+		}
+		if r, ok := state.Reputation[id]; ok && r.Skip(now) {
+			until := r.QuarantineUntil.In(config.Get().Location()).Format("2006-01-02 15:04")
+			report.Skip(p, StageReputation, fmt.Sprintf("quarantine until %s, score %d", until, r.Score()))
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// observeReputation adds the healthcheck results of the tested proxies to their records, alive are the ones passed.
+// The records not checked for reputation_forget_days are dropped
+func observeReputation(tested proxy.ProxyList, alive proxy.ProxyList, infos proxyInfos, now time.Time) {
+	policy := config.Get().ReputationPolicy()
+	passed := make(map[proxy.Proxy]bool, len(alive))
+	for _, p := range alive {
+		passed[p] = true
+	}
+	cache.Update(func(s *cache.State) {
+		records := make(map[string]reputation.Record, len(s.Reputation))
+		for id, r := range s.Reputation {
+			if !r.Forgotten(now, policy) {
+				records[id] = r
+			}
+		}
+		for _, p := range tested {
+			id := p.Identifier()
+			info := infos.get(p)
+			r := records[id]
+			r.Name = p.BaseInfo().Name
+			r.Type = p.TypeName()
+			r.Server = info.Host
+			if r.Server == "" {
+				r.Server = p.BaseInfo().Server
+			}
+			r.Observe(passed[p], time.Duration(info.DelayMs)*time.Millisecond, now, policy, s.Overrides[id] == reputation.Pin)
+			records[id] = r
+		}
+		s.Reputation = records
+
+		counts := map[string]int{reputation.Good: 0, reputation.Probation: 0, reputation.Quarantine: 0}
+		for _, r := range records {
+			counts[r.State]++
+		}
+		metrics.SetReputation(counts)
+	})
+}
+
+var ErrBadOverride = errors.New("action must be pin, ban or clear")
+
+// SetOverride pins or bans a proxy by Identifier(), clear removes the override. A banned one is dropped from
+// the published proxies at once, a run going on drops it when it publishes
+func SetOverride(id string, action string) error {
+	if action == "clear" {
+		action = ""
+	}
+	if action != reputation.Pin && action != reputation.Ban && action != "" {
+		return ErrBadOverride
+	}
+	cache.Update(func(s *cache.State) {
+		overrides := maps.Clone(s.Overrides)
+		if overrides == nil {
+			overrides = make(map[string]string)
+		}
+		if action == "" {
+			delete(overrides, id)
+		} else {
+			overrides[id] = action
+		}
+		s.Overrides = overrides
+		if action != reputation.Ban {
+			return
+		}
+		for _, p := range s.Proxies {
+			if p.Identifier() == id {
+				setProxies(s, s.Proxies, s.ProxyInfos)
+				return
+			}
+		}
+	})
+	return SaveCache()
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/resolver"
+)
+
+var (
+	dnsResolver     *resolver.Resolver
+	resolverOptions resolver.Options
+	resolverMutex   sync.Mutex
+)
+
+// currentResolver is the resolver of the dns options in use. It is made again with an empty cache when they change
+func currentResolver() *resolver.Resolver {
+	opts := config.Get().ResolverOptions()
+	resolverMutex.Lock()
+	defer resolverMutex.Unlock()
+	if dnsResolver != nil && reflect.DeepEqual(opts, resolverOptions) {
+		return dnsResolver
+	}
+	r, err := resolver.New(opts)
+	if err != nil {
+		// the servers are checked with the config, this is not expected
+		log.Printf("[Andy] DNS options error: %s, use the system resolver", err)
+		opts.Servers = nil
+		r, _ = resolver.New(opts)
+	}
+	dnsResolver, resolverOptions = r, opts
+	return r
+}
+
+// resolveServers replaces the servers of the proxies with their ips, the hosts are kept in the infos.
+// The proxies which can not be resolved are dropped and logged by host, the allowed ones are kept with the host
+func resolveServers(ctx context.Context, report *RunReport, proxies proxy.ProxyList, allowed proxy.ProxyList, infos proxyInfos) (proxy.ProxyList, error) {
+	hosts := make([]string, 0, len(proxies)+len(allowed))
+	for _, ps := range []proxy.ProxyList{proxies, allowed} {
+		for _, p := range ps {
+			hosts = append(hosts, p.BaseInfo().Server)
+		}
+	}
+	start := time.Now()
+	results := currentResolver().ResolveAll(ctx, hosts)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	cached, failed := 0, 0
+	for _, res := range results {
+		if res.Cached {
+			cached++
+		}
+		if res.Err != nil {
+			failed++
+		}
+	}
+	log.Printf("[Andy] Resolved %d hosts in %s, %d from cache, %d failed", len(results), time.Since(start).Round(time.Millisecond), cached, failed)
+
+	resolved := make(proxy.ProxyList, 0, len(proxies))
+	dropped := make(map[string][]string) // names by host
+	for _, p := range proxies {
+		host := p.BaseInfo().Server
+		res := results[host]
+		if res.Err != nil {
+			report.Skip(p, StageResolve, res.Err.Error())
+			dropped[host] = append(dropped[host], p.BaseInfo().Name)
+			continue
+		}
+		setServerIP(p, res.IPs, infos)
+		resolved = append(resolved, p)
+	}
+	for _, p := range allowed {
+		res := results[p.BaseInfo().Server]
+		if res.Err != nil {
+			log.Printf("[Andy] Allowed proxy %s is kept with the host: %s", p.BaseInfo().Name, res.Err)
+			continue
+		}
+		setServerIP(p, res.IPs, infos)
+	}
+
+	droppedHosts := make([]string, 0, len(dropped))
+	for host := range dropped {
+		droppedHosts = append(droppedHosts, host)
+	}
+	sort.Strings(droppedHosts)
+	for _, host := range droppedHosts {
+		names := dropped[host]
+		log.Printf("[Andy] Drop %d proxies, %s: %s", len(names), results[host].Err, strings.Join(names, ", "))
+	}
+	return resolved, nil
+}
+
+// setServerIP tests p on the first of the ips, all of them are kept in the infos
+func setServerIP(p proxy.Proxy, ips []net.IP, infos proxyInfos) {
+	info := infos.get(p)
+	info.Host = p.BaseInfo().Server
+	info.IPs = make([]string, 0, len(ips))
+	for _, ip := range ips {
+		info.IPs = append(info.IPs, ip.String())
+	}
+	p.SetIP(info.IPs[0])
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"log"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+func init() {
+	// the deny rules of the config drop the published proxies at once too, a proxy no longer denied comes back in the next run
+	config.OnReload(func(old, new config.ConfigOptions) {
+		if len(old.DenyRules) > 0 || len(new.DenyRules) > 0 {
+			cache.Update(func(s *cache.State) {
+				setProxies(s, s.Proxies, s.ProxyInfos)
+			})
+		}
+	})
+}
+
+// rules are the ones of the config followed by the ones set by the api
+func rules(s *cache.State) (allow []config.Rule, deny []config.Rule) {
+	c := config.Get()
+	allow = append(append(make([]config.Rule, 0, len(c.AllowRules)+len(s.AllowRules)), c.AllowRules...), s.AllowRules...)
+	deny = append(append(make([]config.Rule, 0, len(c.DenyRules)+len(s.DenyRules)), c.DenyRules...), s.DenyRules...)
+	return allow, deny
+}
+
+// ruleTarget is p for the rules. The host before it was resolved and the name in the source are taken from info,
+// the published proxies are resolved and renamed
+func ruleTarget(p proxy.Proxy, info cache.ProxyInfo) config.RuleTarget {
+	b := p.BaseInfo()
+	host, name := info.Host, info.Name
+	if host == "" {
+		host = b.Server
+	}
+	if name == "" {
+		name = b.Name
+	}
+	return config.RuleTarget{
+		Host:       host,
+		Server:     b.Server,
+		Port:       b.Port,
+		Type:       p.TypeName(),
+		Name:       name,
+		Identifier: p.Identifier(),
+	}
+}
+
+// applyRules drops the proxies a deny rule matches and puts aside the ones an allow rule matches,
+// they are served without the checks. Deny wins over allow
+func applyRules(report *RunReport, proxies proxy.ProxyList, infos proxyInfos) (checked proxy.ProxyList, allowed proxy.ProxyList) {
+	allow, deny := rules(cache.Current())
+	checked = make(proxy.ProxyList, 0, len(proxies))
+	for _, p := range proxies {
+		t := ruleTarget(p, *infos.get(p))
+		if r := config.MatchRule(deny, t); r != nil {
+			report.Skip(p, StageRules, "denied by rule "+r.String())
+			continue
+		}
+		if config.MatchRule(allow, t) != nil {
+			allowed = append(allowed, p)
+			continue
+		}
+		checked = append(checked, p)
+	}
+	return checked, allowed
+}
+
+// dropDenied drops the proxies a deny rule matches, for the allowed ones after they are resolved
+func dropDenied(report *RunReport, proxies proxy.ProxyList, infos proxyInfos) proxy.ProxyList {
+	_, deny := rules(cache.Current())
+	result := make(proxy.ProxyList, 0, len(proxies))
+	for _, p := range proxies {
+		if r := config.MatchRule(deny, ruleTarget(p, *infos.get(p))); r != nil {
+			report.Skip(p, StageRules, "denied by rule "+r.String())
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// SetRules replaces the rules set by the api. The published proxies a deny rule matches now are dropped at once,
+// the allowed ones are added by the next run
+func SetRules(allow []config.Rule, deny []config.Rule) error {
+	cache.Update(func(s *cache.State) {
+		s.AllowRules = allow
+		s.DenyRules = deny
+		setProxies(s, s.Proxies, s.ProxyInfos)
+	})
+	log.Printf("[Andy] Rules set by the api: %d allow, %d deny", len(allow), len(deny))
+	return SaveCache()
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/qiuchao/proxypool/pkg/healthcheck"
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// HealthcheckCached checks the cached proxies again without fetching, the dead ones are dropped from the cache.
+// It is skipped when another run is queued or going on, the next tick does it
+func HealthcheckCached() error {
+	return runIdle(RunHealthcheck)
+}
+
+// SpeedtestCached runs the third part speed test on the cached proxies, they are renamed with the new results
+func SpeedtestCached() error {
+	if !config.Get().ThirdpartSpeedtest {
+		log.Println("[Andy] Skip speedtest_cron, thirdpart_speedtest is off")
+		return nil
+	}
+	return runIdle(RunSpeedtest)
+}
+
+func runIdle(kind string) error {
+	run := startIdle(kind)
+	if run == nil {
+		log.Printf("[Andy] Skip %s, another run is going on", kind)
+		return nil
+	}
+	return run.Wait()
+}
+
+type cachedStage func(ctx context.Context, report *RunReport, proxies proxy.ProxyList, infos proxyInfos, records checkRecords) (proxy.ProxyList, error)
+
+func healthcheckStage(ctx context.Context, report *RunReport, proxies proxy.ProxyList, infos proxyInfos, records checkRecords) (proxy.ProxyList, error) {
+	healthcheck.DelayConn = config.Get().HealthCheckConnection
+	healthcheck.DelayTimeout = time.Duration(config.Get().HealthCheckTimeout) * time.Second
+	report.StartStage(StageHealthcheck, len(proxies))
+	// all the cached ones are tested again, check_ttl is for the full runs
+	alive, err := healthCheck(ctx, proxies)
+	if err != nil {
+		return nil, err
+	}
+	infos.setDelays(alive)
+	checkFamilies(ctx, alive, infos)
+	now := time.Now()
+	records.update(proxies, alive, infos, now)
+	observeReputation(proxies, alive, infos, now)
+	proxies = alive
+	report.EndStage(len(proxies))
+	log.Println("[Andy] After healthcheck, usable proxy count: ", len(proxies))
+	return proxies, nil
+}
+
+func speedtestStage(ctx context.Context, report *RunReport, proxies proxy.ProxyList, infos proxyInfos, _ checkRecords) (proxy.ProxyList, error) {
+	report.StartStage(StageThirdpartSpeedtest, len(proxies))
+	proxies, testResults := ThirdpartSpeedTest(ctx, proxies, report)
+	infos.setResults(proxies, testResults)
+	report.EndStage(len(proxies))
+	log.Println("[Andy] After third part speed test, usable proxy count: ", len(proxies))
+	if len(proxies) == 0 {
+		return proxies, nil
+	}
+	report.StartStage(StageBaseInfo, len(proxies))
+	if err := UpdateProxyBaseInfo(proxies, infos); err != nil {
+		log.Printf("[Andy] Update proxy base info error: %s", err)
+		report.AddError(StageBaseInfo, err)
+	}
+	report.EndStage(len(proxies))
+	// stopped in the middle, the tested ones are saved
+	return proxies, ctx.Err()
+}
+
+// runCached runs a stage on a copy of the cached proxies and puts the result back into the cache.
+// The stage gives the proxies to save even when it returns an error, nil for nothing to save
+func runCached(ctx context.Context, report *RunReport, stage cachedStage) error {
+	state := cache.Current()
+	if len(state.Proxies) == 0 {
+		return nil
+	}
+	log.Printf("[Andy] Start %s of %d cached proxies", report.Kind, len(state.Proxies))
+	proxies := state.CloneProxies()
+	infos := newProxyInfos(proxies, state.ProxyInfos)
+	// the allowed ones are not checked, they are put back as they are
+	proxies, allowed := applyRules(report, proxies, infos)
+	records := newCheckRecords(state.Checks, nil)
+	proxies, err := stage(ctx, report, proxies, infos, records)
+	if proxies == nil && err != nil {
+		return err
+	}
+	proxies = append(allowed, proxies...)
+	if len(proxies) == 0 {
+		return errors.New("no usable proxy after check, keep the last result")
+	}
+	saveResult(report, proxies, infos, state.AllProxiesCount, records)
+	return err
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// LoadCache restores the result of last run, so the web server has proxies to serve before the first check finishes
+func LoadCache() error {
+	store, err := cache.NewStore(config.Get().CacheType, config.Get().CacheFile)
+	if err != nil {
+		return err
+	}
+	snapshot, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cache.RestoreSnapshot(snapshot, convert2Proxy)
+	log.Printf("[Andy] Load cache from %s, proxies: %d", config.Get().CacheFile, len(snapshot.Proxies))
+	return nil
+}
+
+var saveMutex sync.Mutex
+
+// SaveCache writes the current cache to the configured store
+func SaveCache() error {
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+	store, err := cache.NewStore(config.Get().CacheType, config.Get().CacheFile)
+	if err != nil {
+		return err
+	}
+	return store.Save(cache.TakeSnapshot())
+}
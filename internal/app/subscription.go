@@ -0,0 +1,96 @@
+package app
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypool/pkg/tool"
+)
+
+// parseSubscription reads a base64 encoded (or plain) list of share links
+func parseSubscription(content string) proxy.ProxyList {
+	text := strings.TrimSpace(content)
+	if !strings.Contains(text, "://") {
+		// base64 may be wrapped into several lines
+		decoded, err := tool.Base64DecodeString(strings.Join(strings.Fields(text), ""))
+		if err == nil {
+			text = decoded
+		}
+	}
+
+	proxyList := make(proxy.ProxyList, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		p, err := parseProxyFromLink(line)
+		if err == nil && p != nil {
+			proxyList = append(proxyList, p)
+		}
+	}
+	return proxyList
+}
+
+// Convert share link (vmess:// ss:// ssr:// trojan:// vless:// hysteria2:// tuic://) to proxy
+func parseProxyFromLink(link string) (proxy.Proxy, error) {
+	var p proxy.Proxy
+	switch {
+	case strings.HasPrefix(link, "ssr://"):
+		ssr, err := proxy.ParseSSRLink(link)
+		if err != nil {
+			return nil, err
+		}
+		p = ssr
+	case strings.HasPrefix(link, "vmess://"):
+		vmess, err := proxy.ParseVmessLink(link)
+		if err != nil {
+			return nil, err
+		}
+		p = vmess
+	case strings.HasPrefix(link, "ss://"):
+		ss, err := proxy.ParseSSLink(link)
+		if err != nil {
+			return nil, err
+		}
+		p = ss
+	case strings.HasPrefix(link, "trojan://"), strings.HasPrefix(link, "trojan-go://"):
+		trojan, err := proxy.ParseTrojanLink(link)
+		if err != nil {
+			return nil, err
+		}
+		p = trojan
+	case strings.HasPrefix(link, "vless://"):
+		vless, err := ParseVlessLink(link)
+		if err != nil {
+			return nil, err
+		}
+		p = vless
+	case strings.HasPrefix(link, "hysteria2://"), strings.HasPrefix(link, "hy2://"):
+		hysteria2, err := ParseHysteria2Link(link)
+		if err != nil {
+			return nil, err
+		}
+		p = hysteria2
+	case strings.HasPrefix(link, "tuic://"):
+		tuic, err := ParseTuicLink(link)
+		if err != nil {
+			return nil, err
+		}
+		p = tuic
+	default:
+		return nil, errors.New("unsupported link: " + link)
+	}
+
+	// ss and trojan parsers leave the remark in the fragment
+	if p.BaseInfo().Name == "" {
+		if i := strings.LastIndex(link, "#"); i >= 0 {
+			if name, err := url.PathUnescape(link[i+1:]); err == nil {
+				p.SetName(name)
+			}
+		}
+	}
+	return p, nil
+}
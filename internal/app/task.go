@@ -1,168 +1,263 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Dreamacro/clash/adapter"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/oschwald/geoip2-golang"
 	"github.com/qiuchao/proxypool/pkg/healthcheck"
-	"github.com/qiuchao/proxypool/pkg/provider"
 	"github.com/qiuchao/proxypool/pkg/proxy"
 	"github.com/qiuchao/proxypoolCheck/config"
 	"github.com/qiuchao/proxypoolCheck/internal/cache"
+	"github.com/qiuchao/proxypoolCheck/internal/metrics"
+	"github.com/qiuchao/proxypoolCheck/internal/provider"
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
+	"io"
 	"log"
-	"time"
-	"sync"
-	"sync/atomic"
 	"net"
 	"net/http"
-	"context"
-	"io"
-	"github.com/Dreamacro/clash/adapter"
-	C "github.com/Dreamacro/clash/constant"
-	"encoding/json"
-	"regexp"
-	"sort"
-	"strings"
-	"github.com/oschwald/geoip2-golang"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var location, _ = time.LoadLocation("PRC")
+// Get all usable proxies from proxypool server and set app vars.
+// A failed run keeps the last good result in cache, errors and dropped proxies are recorded in the run report.
+// It joins the full run already queued or running, if any
+func InitApp() error {
+	run, _ := StartRun(RunFull, nil)
+	return run.Wait()
+}
 
-// Get all usable proxies from proxypool server and set app vars
-func InitApp() error{
-	if cache.AllProxiesCount > 0 && IsSleepTime() {
-		return nil
-	}
+// CheckFiles checks the proxies of the changed local files only. The other cached proxies are kept as they are,
+// the cached proxies of the files are replaced
+func CheckFiles(files []string) error {
+	run, _ := StartRun(RunFiles, files)
+	return run.Wait()
+}
 
-	log.Printf("[Andy] Start running proxypool check...")
+// runCheck checks all sources when files is nil. When ctx is done before the third part speed test, the last result is kept.
+// A speed test stopped in the middle keeps the proxies not tested yet, and the result is saved
+func runCheck(ctx context.Context, report *RunReport, files []string) error {
+	partial := files != nil
+
+	jobs := sourceJobs()
+	state := cache.Current()
+	lastProxies := state.CloneProxies() // the published ones are read by the web server, never changed
+	infos := newProxyInfos(lastProxies, state.ProxyInfos)
+	var kept proxy.ProxyList // not checked in a partial run
+	if partial {
+		log.Printf("[Andy] Start checking changed files: %s", strings.Join(files, ", "))
+		jobs, kept = partialCheck(files, jobs, lastProxies, infos)
+		lastProxies = nil
+	} else {
+		log.Printf("[Andy] Start running proxypool check...")
+	}
 	// Get proxies from server
-	proxies, err := getAllProxies()
-	if err != nil {
+	report.StartStage(StageFetch, len(jobs))
+	proxies, err := getAllProxies(ctx, report, infos, jobs)
+	report.EndStage(len(proxies))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && !partial {
 		log.Println("Get proxies error: ", err)
-		cache.LastCrawlTime = fmt.Sprint(time.Now().In(location).Format("2006-01-02 15:04:05"), err)
+		cache.Update(func(s *cache.State) {
+			s.LastCrawlTime = fmt.Sprint(config.Now().Format("2006-01-02 15:04:05"), err)
+		})
 		return err
 	}
-	badProxies := cache.GetBadProxies()
-	if config.Config.ToBadProxyTimes > 0 {
-		for nodeId, _ := range badProxies {
-			badProxies[nodeId]++
-			if badProxies[nodeId] > (config.Config.SkipBadProxyTimes + config.Config.ToBadProxyTimes + 1) {
-				delete(badProxies, nodeId)
-			}
-		}
-	}
-	lastProxies := cache.GetProxies("proxies")
-	proxylist := make(proxy.ProxyList, 0, len(proxies) + len(lastProxies))
-	for _, p := range lastProxies {
-		proxylist = append(proxylist, p)
-	}
-	for _, p := range proxies {
-		ips, err := net.LookupIP(p.BaseInfo().Server)
-		if err != nil {
-			continue
-		}
-		ip := net.ParseIP(ips[0].String())
-		p.SetIP(ip.String())
-		nodeId := p.Identifier()
-		if badProxies[nodeId] > config.Config.ToBadProxyTimes {
-			// log.Printf("[Andy] Skip proxy by bad proxies, name: %s bad times: %d", p.BaseInfo().Name, badProxies[nodeId] - 1)
-			continue
-		}
-		proxylist = append(proxylist, p)
+	// the allowed ones skip all the checks below, they are served on top of max_proxy_count
+	report.StartStage(StageRules, len(proxies)+len(lastProxies))
+	proxies, allowed := applyRules(report, proxies, infos)
+	lastProxies, lastAllowed := applyRules(report, lastProxies, infos)
+	report.EndStage(len(proxies) + len(allowed) + len(lastProxies) + len(lastAllowed))
+
+	report.StartStage(StageResolve, len(proxies)+len(allowed))
+	resolved, err := resolveServers(ctx, report, proxies, allowed, infos)
+	if err != nil {
+		return err
 	}
-	log.Println("[Andy] Origin proxies:", len(proxylist))
-	proxies = proxylist.Derive().Deduplication()
+	// the ip and cidr rules match the resolved servers
+	resolved, resolvedAllowed := applyRules(report, resolved, infos)
+	allowed = append(dropDenied(report, allowed, infos), resolvedAllowed...)
+	allowed = append(allowed, lastAllowed...).Deduplication()
+	report.EndStage(len(resolved) + len(allowed))
+
+	report.StartStage(StageReputation, len(resolved)+len(lastProxies))
+	now := time.Now()
+	proxylist := reputationFilter(report, resolved, now)
+	lastProxies = reputationFilter(report, lastProxies, now) // banned by hand since the last run
+	report.EndStage(len(proxylist) + len(lastProxies))
+	log.Println("[Andy] Origin proxies:", len(proxylist)+len(lastProxies))
+	report.StartStage(StageDedup, len(proxylist)+len(lastProxies))
+	proxies = infos.deduplicate(lastProxies, proxylist, sourceWeights())
+	report.EndStage(len(proxies))
 	allProxiesCount := len(proxies)
 	log.Println("[Andy] Unique proxies:", len(proxies))
 
 	// healthcheck settings
-	healthcheck.DelayConn = config.Config.HealthCheckConnection
-	healthcheck.DelayTimeout = time.Duration(config.Config.HealthCheckTimeout) * time.Second
-	healthcheck.SpeedConn = config.Config.SpeedConnection
-	healthcheck.SpeedTimeout = time.Duration(config.Config.SpeedTimeout) * time.Second
+	healthcheck.DelayConn = config.Get().HealthCheckConnection
+	healthcheck.DelayTimeout = time.Duration(config.Get().HealthCheckTimeout) * time.Second
+	healthcheck.SpeedConn = config.Get().SpeedConnection
+	healthcheck.SpeedTimeout = time.Duration(config.Get().SpeedTimeout) * time.Second
 
 	testResults := make([]Result, 0, len(proxies))
 	log.Printf("[Andy] Start healthcheck")
 
-	if config.Config.ToBadProxyTimes > 0 {
-		for _, p := range proxies {
-			nodeId := p.Identifier()
-			if badProxies[nodeId] <= 0 {
-				badProxies[nodeId] = 1
-			}
-		}
+	report.StartStage(StageHealthcheck, len(proxies))
+	keep := proxies
+	if partial {
+		keep = nil // the records of the other files are not touched
 	}
-	proxies = healthcheck.CleanBadProxiesWithGrpool(proxies)
+	records := newCheckRecords(state.Checks, keep)
+	proxies, err = incrementalHealthCheck(ctx, report, proxies, infos, records)
+	if err != nil {
+		return err
+	}
+	report.EndStage(len(proxies))
 	log.Println("[Andy] After healthcheck, usable proxy count: ", len(proxies))
-	if config.Config.SpeedTest == true {
-		proxies = healthcheck.SpeedTestAll(proxies)
+	if config.Get().SpeedTest == true {
+		report.StartStage(StageSpeedtest, len(proxies))
+		supported, others := splitByCoreSupport(proxies)
+		proxies = append(inBatches(ctx, supported, healthcheck.SpeedConn, healthcheck.SpeedTestAll), others...)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report.EndStage(len(proxies))
 		log.Println("[Andy] After speed test, usable proxy count: ", len(proxies))
 	}
-	if config.Config.ThirdpartSpeedtest == true {
-		proxies, testResults = ThirdpartSpeedTest(proxies)
+	if config.Get().ThirdpartSpeedtest == true && config.Get().SpeedtestCron != "" {
+		log.Println("[Andy] Skip third part speed test, it runs by speedtest_cron")
+	} else if config.Get().ThirdpartSpeedtest == true {
+		report.StartStage(StageThirdpartSpeedtest, len(proxies))
+		proxies, testResults = ThirdpartSpeedTest(ctx, proxies, report)
+		infos.setResults(proxies, testResults)
+		report.EndStage(len(proxies))
 		log.Println("[Andy] After third part speed test, usable proxy count: ", len(proxies))
 	}
-	if config.Config.ToBadProxyTimes > 0 {
-		for _, p := range proxies {
-			nodeId := p.Identifier()
-			badProxies[nodeId] = badProxies[nodeId] - 2
-			if badProxies[nodeId] < 0 {
-				badProxies[nodeId] = 0
-			}
-		}
-		cache.SetBadProxies(badProxies)
+	if len(allowed) > 0 {
+		log.Println("[Andy] Allowed by rules without checks: ", len(allowed))
+	}
+	allProxiesCount += len(allowed)
+	updateSourceStats(report, infos, append(allowed[:len(allowed):len(allowed)], proxies...))
+	if partial {
+		allProxiesCount += len(kept)
+		proxies = append(proxies, kept...).Deduplication()
 	}
 
-	if len(proxies) > config.Config.MaxProxyCount {
-		proxies = proxies[:config.Config.MaxProxyCount]
+	if len(proxies)+len(allowed) == 0 {
+		// keep the last good result for clients
+		return errors.New("no usable proxy after check, keep the last result")
 	}
-	UpdateProxyBaseInfo(proxies, testResults)
 
-	cache.AllProxiesCount = allProxiesCount
-	cache.SSProxiesCount = proxies.TypeLen("ss")
-	cache.SSRProxiesCount = proxies.TypeLen("ssr")
-	cache.VmessProxiesCount = proxies.TypeLen("vmess")
-	cache.TrojanProxiesCount = proxies.TypeLen("trojan")
-	cache.UsableProxiesCount = len(proxies)
-	cache.LastCrawlTime = fmt.Sprint(time.Now().In(location).Format("2006-01-02 15:04:05"))
-	cache.SetProxies("proxies", proxies)
+	report.StartStage(StageMaxCount, len(proxies)+len(allowed))
+	if len(proxies) > config.Get().MaxProxyCount {
+		proxies = proxies[:config.Get().MaxProxyCount]
+	}
+	proxies = append(allowed, proxies...).Deduplication()
+	report.EndStage(len(proxies))
+	report.StartStage(StageBaseInfo, len(proxies))
+	if err := UpdateProxyBaseInfo(proxies, infos); err != nil {
+		log.Printf("[Andy] Update proxy base info error: %s", err)
+		report.AddError(StageBaseInfo, err)
+	}
+	report.EndStage(len(proxies))
 
-	cache.SetString("clashproxies", provider.Clash{
-		provider.Base{
-			Proxies: &proxies,
-		},
-	}.Provide())
-	cache.SetString("surgeproxies", provider.Surge{
-		provider.Base{
-			Proxies: &proxies,
-		},
-	}.Provide())
+	saveResult(report, proxies, infos, allProxiesCount, records)
 
-	fmt.Println("Open", config.Config.Domain+":"+config.Config.Port, "to check.")
+	if ctx.Err() != nil {
+		log.Printf("[Andy] Run stopped in the speed test, the proxies not tested yet are kept")
+		return ctx.Err()
+	}
+
+	fmt.Println("Open", config.Get().Domain+":"+config.Get().Port, "to check.")
 
 	ExecFinishCmd()
 
 	return nil
 }
 
-func IsSleepTime() bool {
-	sleepStart := config.Config.SleepStart
-	sleepEnd := config.Config.SleepEnd
-	if sleepStart != sleepEnd {
-		currentTime := time.Now()
-		hour := currentTime.Hour()
-		if sleepStart < sleepEnd {
-			if hour >= sleepStart && hour < sleepEnd {
-				log.Printf("[Andy] Skip this execution, sleep time form %d to %d, now: %d", sleepStart, sleepEnd, hour)
-				return true
-			}
-		} else {
-			if (hour >= sleepStart && hour <= 23) || (hour >= 0 && hour < sleepEnd) {
-				log.Printf("[Andy] Skip this execution, sleep time form %d to %d, now: %d", sleepStart, sleepEnd, hour)
-				return true
-			}
+// saveResult publishes the checked proxies with the rendered outputs and the check records in one state and saves it,
+// nil records are left as they are. The proxies belong to the state after it, they must not be changed
+func saveResult(report *RunReport, proxies proxy.ProxyList, infos proxyInfos, allProxiesCount int, records checkRecords) {
+	report.StartStage(StageCache, len(proxies))
+	proxyInfos := infos.byIdentifier(proxies)
+	speeds := speedResults(proxies)
+	cache.Update(func(s *cache.State) {
+		s.Speeds = speeds
+		setProxies(s, proxies, proxyInfos)
+		s.AllProxiesCount = allProxiesCount
+		if records != nil {
+			s.Checks = records
+		}
+		s.LastCrawlTime = fmt.Sprint(config.Now().Format("2006-01-02 15:04:05"))
+	})
+
+	if err := SaveCache(); err != nil {
+		log.Printf("[Andy] Save cache error: %s", err)
+		report.AddError(StageCache, err)
+	}
+	report.EndStage(len(proxies))
+}
+
+// setProxies puts the proxies without the banned and denied ones into s, with the counts and the rendered outputs
+func setProxies(s *cache.State, proxies proxy.ProxyList, proxyInfos map[string]cache.ProxyInfo) {
+	_, deny := rules(s)
+	served := make(proxy.ProxyList, 0, len(proxies))
+	for _, p := range proxies {
+		if s.Overrides[p.Identifier()] == reputation.Ban {
+			continue
+		}
+		if config.MatchRule(deny, ruleTarget(p, proxyInfos[p.Identifier()])) != nil {
+			continue
 		}
+		served = append(served, p)
+	}
+	// the providers change the names, render copies
+	clashProxies := served.Clone()
+	surgeProxies := served.Clone()
+	s.ClashProxies = provider.Clash{
+		Base: provider.Base{
+			Proxies: &clashProxies,
+			Infos:   proxyInfos,
+			Speeds:  s.Speeds,
+		},
+	}.Provide()
+	s.SurgeProxies = provider.Surge{
+		Base: provider.Base{
+			Proxies: &surgeProxies,
+			Infos:   proxyInfos,
+			Speeds:  s.Speeds,
+		},
+	}.Provide()
+	s.Proxies = served
+	s.ProxyInfos = proxyInfos
+	s.SSProxiesCount = served.TypeLen("ss")
+	s.SSRProxiesCount = served.TypeLen("ssr")
+	s.VmessProxiesCount = served.TypeLen("vmess")
+	s.TrojanProxiesCount = served.TypeLen("trojan")
+	s.VlessProxiesCount = served.TypeLen("vless")
+	s.Hysteria2ProxiesCount = served.TypeLen("hysteria2")
+	s.TuicProxiesCount = served.TypeLen("tuic")
+	s.UsableProxiesCount = len(served)
+}
+
+// IsSleepTime tells whether now is in a sleep window, in the configured timezone
+func IsSleepTime() bool {
+	now := config.Now()
+	if w := config.Get().SleepWindowAt(now); w != nil {
+		log.Printf("[Andy] Skip this execution, sleep time %s, now: %s", w, now.Format("Mon 15:04"))
+		return true
 	}
 	return false
 }
@@ -172,23 +267,21 @@ type CountryEmoji struct {
 	Emoji string `json:"emoji"`
 }
 
-func UpdateProxyBaseInfo(proxylist proxy.ProxyList, testResults []Result) {
+// Rename proxies by GeoIP. The proxies keep their names when the resource files can not be read
+func UpdateProxyBaseInfo(proxylist proxy.ProxyList, infos proxyInfos) error {
 	data, err := os.ReadFile("resource/Country-flag-emoji.json")
 	if err != nil {
-		log.Fatal(err)
-		return
+		return err
 	}
 	var countryEmojiList = make([]CountryEmoji, 0)
 	err = json.Unmarshal(data, &countryEmojiList)
 	if err != nil {
-		log.Fatalln(err.Error())
-		return
+		return fmt.Errorf("Country-flag-emoji.json: %w", err)
 	}
 	// download form --> https://github.com/P3TERX/GeoLite.mmdb/releases
 	db, err := geoip2.Open("resource/GeoLite2-City.mmdb")
 	if err != nil {
-		log.Println("[Andy] Open GeoLite2-City.mmdb failure: %s", err)
-		return
+		return fmt.Errorf("open GeoLite2-City.mmdb failure: %w", err)
 	}
 	defer db.Close()
 
@@ -204,7 +297,7 @@ func UpdateProxyBaseInfo(proxylist proxy.ProxyList, testResults []Result) {
 		if err != nil {
 			continue
 		}
-		
+
 		country := "🏁ZZ"
 		countryName := record.Country.Names["en"]
 		city := record.City.Names["en"]
@@ -215,7 +308,13 @@ func UpdateProxyBaseInfo(proxylist proxy.ProxyList, testResults []Result) {
 			country = fmt.Sprintf("%v %v", emoji, record.Country.Names["zh-CN"])
 		}
 
-		originName := p.BaseInfo().Name
+		info := infos.get(p)
+		info.CountryCode = countryIsoCode
+		info.CountryName = countryName
+		info.Region = city
+		if len(record.Subdivisions) > 0 {
+			info.Region = record.Subdivisions[0].Names["en"]
+		}
 		p.SetCountry(country)
 		p.SetName(countryIsoCode)
 		p.AddToName(fmt.Sprintf("_%s", countryName))
@@ -226,29 +325,27 @@ func UpdateProxyBaseInfo(proxylist proxy.ProxyList, testResults []Result) {
 		}
 		countMap[countryName]++
 		p.AddToName(fmt.Sprintf("_%.02d", countMap[countryName]))
-		for _, result := range testResults {
-			if result.Name == originName {
-				if config.Config.SpeedSort == 2 {
-					p.AddToName(fmt.Sprintf("|%s", formatMilliseconds(result.TTFB)))
-				} else {
-					p.AddToName(fmt.Sprintf("|%s", strings.ReplaceAll(formatBandwidth(result.Bandwidth), "/s", "")))
-				}
-				break
+		// the speed test result, the proxies kept by a partial run have it from the last run
+		if config.Get().ThirdpartSpeedtest && (info.Bandwidth != -1 || info.TTFBMs != -1) {
+			if config.Get().SpeedSort == 2 {
+				p.AddToName(fmt.Sprintf("|%s", formatMilliseconds(time.Duration(info.TTFBMs)*time.Millisecond)))
+			} else {
+				p.AddToName(fmt.Sprintf("|%s", strings.ReplaceAll(formatBandwidth(info.Bandwidth), "/s", "")))
 			}
 		}
-		// log.Printf("[Andy] Rename proxy: %s\tto: %s", originName, p.BaseInfo().Name)
 	}
+	return nil
 }
 
 func ExecFinishCmd() {
-	finishCmd := config.Config.FinishCmd
+	finishCmd := config.Get().FinishCmd
 	if finishCmd != "" {
 		if runtime.GOOS == "windows" {
 			cmd := exec.Command("cmd", finishCmd)
 			output, err := cmd.CombinedOutput()
 			if err != nil {
 				fmt.Printf("[Andy] Execute command(%s) error: %s", cmd, err)
-			} else {					
+			} else {
 				fmt.Printf("[Andy] Execute command(%s) finish. result:\n%s", cmd, string(output))
 			}
 		} else if runtime.GOOS == "linux" {
@@ -257,14 +354,13 @@ func ExecFinishCmd() {
 			output, err := cmd.Output()
 			if err != nil {
 				fmt.Printf("[Andy] Execute command(%s) error: %s", cmd, err)
-			} else {					
+			} else {
 				fmt.Printf("[Andy] Execute command(%s) finish. result:\n%s", cmd, string(output))
 			}
 		}
 	}
 }
 
-
 // Third part speed test from: https://github.com/faceair/clash-speedtest
 
 type Result struct {
@@ -276,7 +372,7 @@ type Result struct {
 type CProxy struct {
 	C.Proxy
 	SecretConfig any
-	OriginProxy proxy.Proxy
+	OriginProxy  proxy.Proxy
 }
 
 var (
@@ -289,10 +385,17 @@ var (
 	spaceRegex = regexp.MustCompile(`\s{2,}`)
 )
 
-func ThirdpartSpeedTest(proxylist proxy.ProxyList) (proxy.ProxyList, []Result) {
+// Proxies which can not be tested are dropped and recorded in the report.
+// When ctx is done, the proxies not tested yet are kept at the end of the list, without results
+func ThirdpartSpeedTest(ctx context.Context, proxylist proxy.ProxyList, report *RunReport) (proxy.ProxyList, []Result) {
 	log.Println("[Andy] Start third part speed test")
 	allProxies := make(map[string]CProxy)
+	probeProxies := make(map[string]proxy.Proxy) // can not be dialed by clash core, only test the delay
 	for _, value := range proxylist {
+		if !isCoreSupported(value) {
+			probeProxies[value.BaseInfo().Name] = value
+			continue
+		}
 		proxyStr := value.ToClash()
 		re := regexp.MustCompile("- {")
 		proxyStr = re.ReplaceAllString(proxyStr, "{")
@@ -300,17 +403,18 @@ func ThirdpartSpeedTest(proxylist proxy.ProxyList) (proxy.ProxyList, []Result) {
 		var proxyConfig map[string]interface{}
 		err := json.Unmarshal([]byte(proxyStr), &proxyConfig)
 		if err != nil {
+			report.Skip(value, StageThirdpartSpeedtest, err.Error())
 			continue
 		}
 
 		p, err := adapter.ParseProxy(proxyConfig)
 		if err != nil {
-			fmt.Errorf("proxy %w", err)
+			report.Skip(value, StageThirdpartSpeedtest, err.Error())
 			continue
 		}
 
 		if _, exist := allProxies[p.Name()]; exist {
-			fmt.Errorf("proxy %s is the duplicate name", p.Name())
+			report.Skip(value, StageThirdpartSpeedtest, "duplicate name")
 			continue
 		}
 		allProxies[p.Name()] = CProxy{Proxy: p, SecretConfig: proxyConfig, OriginProxy: value}
@@ -325,49 +429,102 @@ func ThirdpartSpeedTest(proxylist proxy.ProxyList) (proxy.ProxyList, []Result) {
 
 	format := "%s%-42s\t%-12s\t%-12s\033[0m\n"
 	fmt.Printf(format, "", "节点", "带宽", "延迟")
+	var untested proxy.ProxyList
 	for _, name := range allProxyNames {
 		proxy := allProxies[name]
+		if ctx.Err() != nil {
+			untested = append(untested, proxy.OriginProxy)
+			continue
+		}
 		switch proxy.Type() {
 		case C.Shadowsocks, C.ShadowsocksR, C.Snell, C.Socks5, C.Http, C.Vmess, C.Trojan:
-			result := TestProxyConcurrent(name, proxy, config.Config.SpeedDownloadSize, time.Duration(config.Config.SpeedTimeout) * time.Second, config.Config.SpeedConnection)
+			result := TestProxyConcurrent(ctx, name, proxy, config.Get().SpeedDownloadSize, time.Duration(config.Get().SpeedTimeout)*time.Second, config.Get().SpeedConnection)
+			if ctx.Err() != nil {
+				// stopped in the middle, the result is not right
+				untested = append(untested, proxy.OriginProxy)
+				continue
+			}
 			result.Printf(format)
 			testResults = append(testResults, *result)
-		case C.Direct, C.Reject, C.Relay, C.Selector, C.Fallback, C.URLTest, C.LoadBalance:
-			continue
 		default:
-			log.Fatalln("Unsupported proxy type: %s", proxy.Type())
+			report.Skip(proxy.OriginProxy, StageThirdpartSpeedtest, fmt.Sprintf("unsupported proxy type: %s", proxy.Type()))
 		}
 	}
 
-	switch config.Config.SpeedSort {
-		case 1:
-			sort.Slice(testResults, func(i, j int) bool {
-				return testResults[i].Bandwidth > testResults[j].Bandwidth
-			})
-			log.Println("[Andy] The results are sorted by bandwidth")
-		case 2:
-			sort.Slice(testResults, func(i, j int) bool {
-				return testResults[i].TTFB < testResults[j].TTFB
-			})
-			log.Println("[Andy] The results are sorted by delay")
-		default:
-			log.Println("[Andy] The results are sorted by proxy name")
+	if len(probeProxies) > 0 {
+		probeList := make(proxy.ProxyList, 0, len(probeProxies))
+		for _, p := range probeProxies {
+			probeList = append(probeList, p)
+		}
+		delays := probeDelays(ctx, probeList)
+		for name, p := range probeProxies {
+			result := &Result{Name: name, Bandwidth: -1, TTFB: -1}
+			delay, ok := delays[p.Identifier()]
+			if !ok && ctx.Err() != nil {
+				untested = append(untested, p)
+				continue
+			}
+			if ok && delay > 0 {
+				result.TTFB = delay
+			}
+			result.Printf(format)
+			if ok {
+				testResults = append(testResults, *result)
+			}
+		}
+	}
+
+	switch config.Get().SpeedSort {
+	case 1:
+		sort.Slice(testResults, func(i, j int) bool {
+			return testResults[i].Bandwidth > testResults[j].Bandwidth
+		})
+		log.Println("[Andy] The results are sorted by bandwidth")
+	case 2:
+		sort.Slice(testResults, func(i, j int) bool {
+			return testResults[i].TTFB < testResults[j].TTFB
+		})
+		log.Println("[Andy] The results are sorted by delay")
+	default:
+		log.Println("[Andy] The results are sorted by proxy name")
+	}
+
+	metrics.ResetProxySpeeds()
+	for _, result := range testResults {
+		var p proxy.Proxy
+		if v, ok := allProxies[result.Name]; ok {
+			p = v.OriginProxy
+		} else if v, ok := probeProxies[result.Name]; ok {
+			p = v
+		} else {
+			continue
+		}
+		metrics.SetProxySpeed(result.Name, p.TypeName(), p.BaseInfo().Server, result.Bandwidth, result.TTFB)
 	}
 
 	var filterProxylist proxy.ProxyList
 	for _, result := range testResults {
-		if result.Bandwidth < config.Config.SpeedMinBandwidth {
+		if p, ok := probeProxies[result.Name]; ok {
+			// bandwidth is unknown, a reachable server with no delay measured is kept
+			ttfb := float64(result.TTFB.Milliseconds())
+			if config.Get().SpeedMaxTtfb > 0 && ttfb > config.Get().SpeedMaxTtfb {
+				continue
+			}
+			filterProxylist = append(filterProxylist, p)
+			continue
+		}
+		if result.Bandwidth < config.Get().SpeedMinBandwidth {
 			continue
 		}
 		ttfb := float64(result.TTFB.Milliseconds())
-		if config.Config.SpeedMaxTtfb > 0 && (ttfb <= 0 || ttfb > config.Config.SpeedMaxTtfb) {
+		if config.Get().SpeedMaxTtfb > 0 && (ttfb <= 0 || ttfb > config.Get().SpeedMaxTtfb) {
 			continue
 		}
 		if v, ok := allProxies[result.Name]; ok {
 			filterProxylist = append(filterProxylist, v.OriginProxy)
 		}
 	}
-	return filterProxylist, testResults
+	return append(filterProxylist, untested...), testResults
 }
 
 func (r *Result) Printf(format string) {
@@ -416,7 +573,7 @@ func formatMilliseconds(v time.Duration) string {
 	return fmt.Sprintf("%.02fms", float64(v.Milliseconds()))
 }
 
-func TestProxyConcurrent(name string, p C.Proxy, downloadSize int, timeout time.Duration, concurrentCount int) *Result {
+func TestProxyConcurrent(ctx context.Context, name string, p C.Proxy, downloadSize int, timeout time.Duration, concurrentCount int) *Result {
 	if concurrentCount <= 0 {
 		concurrentCount = 1
 	}
@@ -430,7 +587,7 @@ func TestProxyConcurrent(name string, p C.Proxy, downloadSize int, timeout time.
 	for i := 0; i < concurrentCount; i++ {
 		wg.Add(1)
 		go func(i int) {
-			result, w := TestProxy(name, p, chunkSize, timeout)
+			result, w := TestProxy(ctx, name, p, chunkSize, timeout)
 			if w != 0 {
 				atomic.AddInt64(&downloaded, w)
 				atomic.AddInt64(&totalTTFB, int64(result.TTFB))
@@ -450,7 +607,7 @@ func TestProxyConcurrent(name string, p C.Proxy, downloadSize int, timeout time.
 	return result
 }
 
-func TestProxy(name string, p C.Proxy, downloadSize int, timeout time.Duration) (*Result, int64) {
+func TestProxy(ctx context.Context, name string, p C.Proxy, downloadSize int, timeout time.Duration) (*Result, int64) {
 	client := http.Client{
 		Timeout: timeout,
 		Transport: &http.Transport{
@@ -467,8 +624,12 @@ func TestProxy(name string, p C.Proxy, downloadSize int, timeout time.Duration)
 		},
 	}
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(config.Get().SpeedServer, downloadSize), nil)
+	if err != nil {
+		return &Result{name, -1, -1}, 0
+	}
 	start := time.Now()
-	resp, err := client.Get(fmt.Sprintf(config.Config.SpeedServer, downloadSize))
+	resp, err := client.Do(req)
 	if err != nil {
 		return &Result{name, -1, -1}, 0
 	}
@@ -487,4 +648,3 @@ func TestProxy(name string, p C.Proxy, downloadSize int, timeout time.Duration)
 
 	return &Result{name, bandwidth, ttfb}, written
 }
-
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+)
+
+var ErrorNotTuicLink = errors.New("not a correct tuic link")
+
+// Tuic is a type of proxy (tuic v5), fields follow Clash.Meta
+type Tuic struct {
+	proxy.Base
+	UUID                 string   `yaml:"uuid" json:"uuid"`
+	Password             string   `yaml:"password" json:"password"`
+	SNI                  string   `yaml:"sni,omitempty" json:"sni,omitempty"`
+	ALPN                 []string `yaml:"alpn,omitempty" json:"alpn,omitempty"`
+	SkipCertVerify       bool     `yaml:"skip-cert-verify,omitempty" json:"skip-cert-verify,omitempty"`
+	CongestionController string   `yaml:"congestion-controller,omitempty" json:"congestion-controller,omitempty"`
+	UdpRelayMode         string   `yaml:"udp-relay-mode,omitempty" json:"udp-relay-mode,omitempty"`
+	ReduceRtt            bool     `yaml:"reduce-rtt,omitempty" json:"reduce-rtt,omitempty"`
+}
+
+/**
+  - name: "tuic"
+    type: tuic
+    server: server
+    port: 443
+    uuid: uuid
+    password: yourpassword
+    # sni: example.com
+    # alpn: [h3]
+    # congestion-controller: bbr
+    # udp-relay-mode: native
+*/
+
+func (t Tuic) Identifier() string {
+	return net.JoinHostPort(t.Server, strconv.Itoa(t.Port)) + t.UUID + t.Password
+}
+
+func (t Tuic) String() string {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (t Tuic) ToClash() string {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return ""
+	}
+	return "- " + string(data)
+}
+
+func (t Tuic) ToSurge() string {
+	return ""
+}
+
+// Clone copies the alpn too
+func (t Tuic) Clone() proxy.Proxy {
+	t.ALPN = slices.Clone(t.ALPN)
+	return &t
+}
+
+func (t Tuic) Link() (link string) {
+	query := url.Values{}
+	if t.SNI != "" {
+		query.Set("sni", t.SNI)
+	}
+	if len(t.ALPN) > 0 {
+		query.Set("alpn", strings.Join(t.ALPN, ","))
+	}
+	if t.SkipCertVerify {
+		query.Set("allow_insecure", "1")
+	}
+	if t.CongestionController != "" {
+		query.Set("congestion_control", t.CongestionController)
+	}
+	if t.UdpRelayMode != "" {
+		query.Set("udp_relay_mode", t.UdpRelayMode)
+	}
+
+	uri := url.URL{
+		Scheme:   "tuic",
+		User:     url.UserPassword(t.UUID, t.Password),
+		Host:     net.JoinHostPort(t.Server, strconv.Itoa(t.Port)),
+		RawQuery: query.Encode(),
+		Fragment: t.Name,
+	}
+	return uri.String()
+}
+
+// keepSNI keeps the domain for tls, the server will be replaced by ip later
+func (t *Tuic) keepSNI() {
+	if t.SNI == "" && net.ParseIP(t.Server) == nil {
+		t.SNI = t.Server
+	}
+}
+
+func ParseTuicLink(link string) (*Tuic, error) {
+	if !strings.HasPrefix(link, "tuic://") {
+		return nil, ErrorNotTuicLink
+	}
+
+	l, ok := parseQUICLink(link, "tuic", 0)
+	if !ok {
+		return nil, ErrorNotTuicLink
+	}
+	password, _ := l.user.Password()
+	if l.user.Username() == "" || password == "" {
+		return nil, ErrorNotTuicLink
+	}
+
+	t := &Tuic{
+		Base:                 l.Base,
+		UUID:                 l.user.Username(),
+		Password:             password,
+		SNI:                  l.sni,
+		SkipCertVerify:       l.query.Get("allow_insecure") == "1" || l.query.Get("insecure") == "1",
+		CongestionController: l.query.Get("congestion_control"),
+		UdpRelayMode:         l.query.Get("udp_relay_mode"),
+	}
+	if alpn := l.query.Get("alpn"); alpn != "" {
+		t.ALPN = strings.Split(alpn, ",")
+	}
+	t.keepSNI()
+	return t, nil
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+)
+
+var ErrorNotVlessLink = errors.New("not a correct vless link")
+
+// Vless is a type of proxy, fields follow Clash.Meta
+type Vless struct {
+	proxy.Base
+	UUID              string           `yaml:"uuid" json:"uuid"`
+	Flow              string           `yaml:"flow,omitempty" json:"flow,omitempty"`
+	TLS               bool             `yaml:"tls,omitempty" json:"tls,omitempty"`
+	ServerName        string           `yaml:"servername,omitempty" json:"servername,omitempty"`
+	SkipCertVerify    bool             `yaml:"skip-cert-verify,omitempty" json:"skip-cert-verify,omitempty"`
+	ClientFingerprint string           `yaml:"client-fingerprint,omitempty" json:"client-fingerprint,omitempty"`
+	Network           string           `yaml:"network,omitempty" json:"network,omitempty"`
+	WSOpts            *proxy.WSOptions `yaml:"ws-opts,omitempty" json:"ws-opts,omitempty"`
+	GrpcOpts          *GrpcOptions     `yaml:"grpc-opts,omitempty" json:"grpc-opts,omitempty"`
+	RealityOpts       *RealityOptions  `yaml:"reality-opts,omitempty" json:"reality-opts,omitempty"`
+}
+
+type GrpcOptions struct {
+	GrpcServiceName string `yaml:"grpc-service-name,omitempty" json:"grpc-service-name,omitempty"`
+}
+
+type RealityOptions struct {
+	PublicKey string `yaml:"public-key" json:"public-key"`
+	ShortID   string `yaml:"short-id,omitempty" json:"short-id,omitempty"`
+}
+
+/**
+  - name: "vless"
+    type: vless
+    server: server
+    port: 443
+    uuid: uuid
+    network: ws
+    tls: true
+    servername: example.com
+    # flow: xtls-rprx-vision
+    # reality-opts:
+    #   public-key: xxx
+    #   short-id: xxx
+*/
+
+func (v Vless) Identifier() string {
+	return net.JoinHostPort(v.Server, strconv.Itoa(v.Port)) + v.UUID
+}
+
+func (v Vless) String() string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (v Vless) ToClash() string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return "- " + string(data)
+}
+
+func (v Vless) ToSurge() string {
+	return ""
+}
+
+// Clone copies the options too, the clone may be changed while the published one is read
+func (v Vless) Clone() proxy.Proxy {
+	if v.WSOpts != nil {
+		ws := *v.WSOpts
+		ws.Headers = maps.Clone(ws.Headers)
+		v.WSOpts = &ws
+	}
+	if v.GrpcOpts != nil {
+		grpc := *v.GrpcOpts
+		v.GrpcOpts = &grpc
+	}
+	if v.RealityOpts != nil {
+		reality := *v.RealityOpts
+		v.RealityOpts = &reality
+	}
+	return &v
+}
+
+// https://github.com/XTLS/Xray-core/discussions/716
+func (v Vless) Link() (link string) {
+	query := url.Values{}
+	query.Set("encryption", "none")
+	switch {
+	case v.RealityOpts != nil:
+		query.Set("security", "reality")
+		query.Set("pbk", v.RealityOpts.PublicKey)
+		if v.RealityOpts.ShortID != "" {
+			query.Set("sid", v.RealityOpts.ShortID)
+		}
+	case v.TLS:
+		query.Set("security", "tls")
+	}
+	if v.ServerName != "" {
+		query.Set("sni", v.ServerName)
+	}
+	if v.ClientFingerprint != "" {
+		query.Set("fp", v.ClientFingerprint)
+	}
+	if v.Flow != "" {
+		query.Set("flow", v.Flow)
+	}
+	if v.SkipCertVerify {
+		query.Set("allowInsecure", "1")
+	}
+	if v.Network != "" {
+		query.Set("type", v.Network)
+	}
+	if v.WSOpts != nil {
+		query.Set("path", v.WSOpts.Path)
+		if host := v.WSOpts.Headers["Host"]; host != "" {
+			query.Set("host", host)
+		}
+	}
+	if v.GrpcOpts != nil {
+		query.Set("serviceName", v.GrpcOpts.GrpcServiceName)
+	}
+
+	uri := url.URL{
+		Scheme:   "vless",
+		User:     url.User(v.UUID),
+		Host:     net.JoinHostPort(v.Server, strconv.Itoa(v.Port)),
+		RawQuery: query.Encode(),
+		Fragment: v.Name,
+	}
+	return uri.String()
+}
+
+// keepSNI keeps the domain for tls, the server will be replaced by ip later
+func (v *Vless) keepSNI() {
+	if v.TLS && v.ServerName == "" && net.ParseIP(v.Server) == nil {
+		v.ServerName = v.Server
+	}
+}
+
+func ParseVlessLink(link string) (*Vless, error) {
+	if !strings.HasPrefix(link, "vless://") {
+		return nil, ErrorNotVlessLink
+	}
+
+	uri, err := url.Parse(link)
+	if err != nil {
+		return nil, ErrorNotVlessLink
+	}
+	port, _ := strconv.Atoi(uri.Port())
+	if port == 0 || uri.User.Username() == "" {
+		return nil, ErrorNotVlessLink
+	}
+
+	query := uri.Query()
+	v := &Vless{
+		Base: proxy.Base{
+			Name:   uri.Fragment,
+			Server: uri.Hostname(),
+			Port:   port,
+			Type:   "vless",
+			UDP:    true,
+		},
+		UUID:              uri.User.Username(),
+		Flow:              query.Get("flow"),
+		ServerName:        query.Get("sni"),
+		ClientFingerprint: query.Get("fp"),
+		SkipCertVerify:    query.Get("allowInsecure") == "1",
+		Network:           query.Get("type"),
+	}
+	switch query.Get("security") {
+	case "tls":
+		v.TLS = true
+	case "reality":
+		v.TLS = true
+		v.RealityOpts = &RealityOptions{
+			PublicKey: query.Get("pbk"),
+			ShortID:   query.Get("sid"),
+		}
+	}
+	v.keepSNI()
+	switch v.Network {
+	case "", "tcp":
+		v.Network = ""
+	case "ws":
+		v.WSOpts = &proxy.WSOptions{Path: query.Get("path")}
+		if host := query.Get("host"); host != "" {
+			v.WSOpts.Headers = map[string]string{"Host": host}
+		}
+	case "grpc":
+		v.GrpcOpts = &GrpcOptions{GrpcServiceName: query.Get("serviceName")}
+	}
+	return v, nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+)
+
+func TestParseVlessLink(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want *Vless
+	}{
+		{
+			name: "tls over ws, sni from the server",
+			link: "vless://11111111-1111-1111-1111-111111111111@example.com:443?encryption=none&security=tls&type=ws&path=%2Fws&host=cdn.example.com#ws",
+			want: &Vless{
+				Base:       proxy.Base{Name: "ws", Server: "example.com", Port: 443, Type: "vless", UDP: true},
+				UUID:       "11111111-1111-1111-1111-111111111111",
+				TLS:        true,
+				ServerName: "example.com",
+				Network:    "ws",
+				WSOpts:     &proxy.WSOptions{Path: "/ws", Headers: map[string]string{"Host": "cdn.example.com"}},
+			},
+		},
+		{
+			name: "reality with vision",
+			link: "vless://uuid@1.2.3.4:8443?security=reality&pbk=key&sid=ab&sni=www.example.com&fp=chrome&flow=xtls-rprx-vision#reality",
+			want: &Vless{
+				Base:              proxy.Base{Name: "reality", Server: "1.2.3.4", Port: 8443, Type: "vless", UDP: true},
+				UUID:              "uuid",
+				Flow:              "xtls-rprx-vision",
+				TLS:               true,
+				ServerName:        "www.example.com",
+				ClientFingerprint: "chrome",
+				RealityOpts:       &RealityOptions{PublicKey: "key", ShortID: "ab"},
+			},
+		},
+		{
+			name: "grpc on an ipv6 server, no sni for an ip",
+			link: "vless://uuid@[2001:db8::1]:443?security=tls&type=grpc&serviceName=svc&allowInsecure=1",
+			want: &Vless{
+				Base:           proxy.Base{Server: "2001:db8::1", Port: 443, Type: "vless", UDP: true},
+				UUID:           "uuid",
+				TLS:            true,
+				SkipCertVerify: true,
+				Network:        "grpc",
+				GrpcOpts:       &GrpcOptions{GrpcServiceName: "svc"},
+			},
+		},
+		{
+			name: "plain tcp keeps no sni",
+			link: "vless://uuid@example.com:80?type=tcp#plain",
+			want: &Vless{
+				Base: proxy.Base{Name: "plain", Server: "example.com", Port: 80, Type: "vless", UDP: true},
+				UUID: "uuid",
+			},
+		},
+		{name: "no port", link: "vless://uuid@example.com"},
+		{name: "no uuid", link: "vless://example.com:443"},
+		{name: "other scheme", link: "vmess://uuid@example.com:443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVlessLink(tt.link)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("ParseVlessLink() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVlessLink() error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseVlessLink() = %+v, want %+v", got, tt.want)
+			}
+			again, err := ParseVlessLink(got.Link())
+			if err != nil {
+				t.Fatalf("ParseVlessLink(Link()) error: %s", err)
+			}
+			if !reflect.DeepEqual(again, got) {
+				t.Fatalf("round trip through %s = %+v, want %+v", got.Link(), again, got)
+			}
+		})
+	}
+}
+
+func TestCloneCopiesOptions(t *testing.T) {
+	v := &Vless{
+		WSOpts:      &proxy.WSOptions{Path: "/ws", Headers: map[string]string{"Host": "a.com"}},
+		GrpcOpts:    &GrpcOptions{GrpcServiceName: "grpc"},
+		RealityOpts: &RealityOptions{PublicKey: "key"},
+	}
+	c := v.Clone().(*Vless)
+	c.WSOpts.Headers["Host"] = "b.com"
+	c.GrpcOpts.GrpcServiceName = "other"
+	c.RealityOpts.PublicKey = "other"
+	if v.WSOpts.Headers["Host"] != "a.com" || v.GrpcOpts.GrpcServiceName != "grpc" || v.RealityOpts.PublicKey != "key" {
+		t.Fatalf("the clone shares options with the original: %+v", v)
+	}
+
+	h := &Hysteria2{ALPN: []string{"h3"}}
+	h.Clone().(*Hysteria2).ALPN[0] = "h2"
+	tu := &Tuic{ALPN: []string{"h3"}}
+	tu.Clone().(*Tuic).ALPN[0] = "h2"
+	if h.ALPN[0] != "h3" || tu.ALPN[0] != "h3" {
+		t.Fatal("the clone shares alpn with the original")
+	}
+}
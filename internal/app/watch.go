@@ -0,0 +1,160 @@
+package app
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+)
+
+// wait for more events before checking, an editor or a copy writes a file several times
+const watchDelay = 2 * time.Second
+
+// WatchLocal watches the local_path directories. A changed file is checked at once, without waiting for the cron
+func WatchLocal() {
+	config.OnReload(func(old, new config.ConfigOptions) {
+		if !reflect.DeepEqual(old.LocalPath, new.LocalPath) {
+			startWatch()
+		}
+	})
+	startWatch()
+}
+
+var (
+	localWatcher *fsnotify.Watcher
+	watchMutex   sync.Mutex
+)
+
+// startWatch closes the running watcher and watches the local_path directories of the current config
+func startWatch() {
+	watchMutex.Lock()
+	defer watchMutex.Unlock()
+	if localWatcher != nil {
+		_ = localWatcher.Close()
+		localWatcher = nil
+	}
+	dirs := localWatchDirs()
+	if len(dirs) == 0 {
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[Andy] Watch local_path error: %s", err)
+		return
+	}
+	for _, dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			log.Printf("[Andy] Watch %s error: %s", dir, err)
+			continue
+		}
+		log.Printf("[Andy] Watching %s", dir)
+	}
+	localWatcher = watcher
+	go watchLoop(watcher)
+}
+
+// watchLoop checks the changed files until the watcher is closed
+func watchLoop(watcher *fsnotify.Watcher) {
+	changed := make(map[string]struct{})
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod || !matchLocalPath(event.Name) {
+				continue
+			}
+			changed[filepath.Clean(event.Name)] = struct{}{}
+			timer.Reset(watchDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[Andy] Watch local_path error: %s", err)
+		case <-timer.C:
+			files := make([]string, 0, len(changed))
+			for file := range changed {
+				files = append(files, file)
+			}
+			sort.Strings(files)
+			changed = make(map[string]struct{})
+			if err := CheckFiles(files); err != nil {
+				log.Printf("[Andy] Check changed files error: %s", err)
+			}
+		}
+	}
+}
+
+// localWatchDirs are the directories of local_path items, or the directories of glob patterns.
+// A wildcard in the directory part of a pattern is expanded, the directories made later are watched after a reload
+func localWatchDirs() []string {
+	seen := make(map[string]bool)
+	dirs := make([]string, 0)
+	for _, s := range config.EnabledSources(config.Get().LocalPath) {
+		pattern := filepath.Clean(s.Url)
+		found := []string{pattern}
+		if info, err := os.Stat(pattern); err != nil || !info.IsDir() {
+			found = globDirs(filepath.Dir(pattern))
+		}
+		for _, dir := range found {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// globDirs is dir itself, or the directories it matches when it has a wildcard
+func globDirs(dir string) []string {
+	if !strings.ContainsAny(dir, `*?[`) {
+		return []string{dir}
+	}
+	matches, err := filepath.Glob(dir)
+	if err != nil {
+		log.Printf("[Andy] Bad local_path directory %s: %s", dir, err)
+		return nil
+	}
+	dirs := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.IsDir() {
+			dirs = append(dirs, m)
+		}
+	}
+	return dirs
+}
+
+// partialCheck keeps the jobs of the changed files, a removed file has no job.
+// The cached proxies of the files are dropped, they are fetched and checked again. The others are kept
+func partialCheck(files []string, jobs []sourceJob, cached proxy.ProxyList, infos proxyInfos) ([]sourceJob, proxy.ProxyList) {
+	changed := make(map[string]bool, len(files))
+	for _, file := range files {
+		changed[file] = true
+	}
+	fileJobs := make([]sourceJob, 0, len(files))
+	for _, job := range jobs {
+		if changed[job.source.Url] {
+			fileJobs = append(fileJobs, job)
+		}
+	}
+	kept := make(proxy.ProxyList, 0, len(cached))
+	for _, p := range cached {
+		if info, ok := infos[p]; ok && changed[info.Source] {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return fileJobs, kept
+}
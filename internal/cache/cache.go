@@ -1,49 +1,117 @@
 package cache
 
 import (
-	"github.com/qiuchao/proxypool/pkg/proxy"
-	"github.com/patrickmn/go-cache"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
 )
 
-var Cache = cache.New(cache.NoExpiration, 10*time.Minute)
+// State is what the runs publish for the web server: the usable proxies with their infos, the counts and the
+// rendered outputs. A published State is never changed, a run builds a new one and publishes it with Update,
+// so a handler reading Current() once sees one consistent result
+type State struct {
+	Proxies     proxy.ProxyList
+	ProxyInfos  map[string]ProxyInfo         // by Identifier()
+	Reputation  map[string]reputation.Record // by Identifier(), written by the runs
+	Overrides   map[string]string            // pin or ban by Identifier(), written by the api only
+	SourceStats map[string]SourceStat        // by url
+	Checks      map[string]CheckRecord       // last healthcheck by Identifier(), of the proxies dropped too
+	Speeds      map[string]float64           // Mb/s of the speed test by Identifier(), a copy of healthcheck.ProxyStats for the handlers
+	AllowRules  []config.Rule                // set by the api, used with the ones of the config
+	DenyRules   []config.Rule
+
+	ClashProxies string // rendered /clash/proxies without filters, empty when not rendered yet
+	SurgeProxies string
+
+	AllProxiesCount       int
+	SSRProxiesCount       int
+	SSProxiesCount        int
+	VmessProxiesCount     int
+	TrojanProxiesCount    int
+	VlessProxiesCount     int
+	Hysteria2ProxiesCount int
+	TuicProxiesCount      int
+	UsableProxiesCount    int
+
+	LastCrawlTime string
+}
+
+var (
+	current     atomic.Pointer[State]
+	updateMutex sync.Mutex
+)
+
+func init() {
+	current.Store(&State{
+		Proxies:       proxy.ProxyList{},
+		ProxyInfos:    make(map[string]ProxyInfo),
+		Reputation:    make(map[string]reputation.Record),
+		Overrides:     make(map[string]string),
+		SourceStats:   make(map[string]SourceStat),
+		Checks:        make(map[string]CheckRecord),
+		LastCrawlTime: "Loading……",
+	})
+}
 
-// Get proxies from Cache
-func GetProxies(key string) proxy.ProxyList {
-	result, found := Cache.Get(key)
-	if found {
-		return result.(proxy.ProxyList) //Get返回的是interface
-	}
-	return nil
+// Current is the last published state, do not change it
+func Current() *State {
+	return current.Load()
 }
 
-// Set proxies to cache
-func SetProxies(key string, proxies proxy.ProxyList) {
-	Cache.Set(key, proxies, cache.NoExpiration)
+// Update publishes a copy of the current state changed by f. f must replace the proxy list and the maps
+// it changes, not change them in place, the readers of the old state still use them
+func Update(f func(s *State)) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+	s := *current.Load()
+	f(&s)
+	current.Store(&s)
 }
 
-// Set string to cache
-func SetString(key, value string) {
-	Cache.Set(key, value, cache.NoExpiration)
+// CloneProxies copies the proxies for the filters, they change the names
+func (s *State) CloneProxies() proxy.ProxyList {
+	return s.Proxies.Clone()
 }
 
-func SetBadProxies(badProxies map[string]int) {
-	Cache.Set("badProxies", badProxies, cache.NoExpiration)
+// ProxyInfo is the test metadata of a cached proxy
+type ProxyInfo struct {
+	Host        string   `json:"host,omitempty"`    // server before it is replaced by the resolved ip
+	Name        string   `json:"name,omitempty"`    // name in the source, before the GeoIP rename
+	IPs         []string `json:"ips,omitempty"`     // all the resolved addresses, the preferred family first
+	IPv4OK      *bool    `json:"ipv4_ok,omitempty"` // reachable over ipv4, nil for not tested
+	IPv6OK      *bool    `json:"ipv6_ok,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	SourceKind  string   `json:"source_kind,omitempty"`
+	CountryCode string   `json:"country_code,omitempty"`
+	CountryName string   `json:"country_name,omitempty"`
+	Region      string   `json:"region,omitempty"`
+	DelayMs     int64    `json:"delay_ms"`  // healthcheck delay, 0 for unknown
+	Bandwidth   float64  `json:"bandwidth"` // bytes/s of the third part speed test, -1 for unknown
+	TTFBMs      int64    `json:"ttfb_ms"`   // -1 for unknown
 }
 
-func GetBadProxies() map[string]int {
-	result, found := Cache.Get("badProxies")
-	if found {
-		return result.(map[string]int)
-	}
-	return make(map[string]int)
+// CheckRecord is the last healthcheck of a proxy, a run reuses it within check_ttl instead of testing again
+type CheckRecord struct {
+	LastChecked time.Time `json:"last_checked"`
+	LastOK      bool      `json:"last_ok"`
+	DelayMs     int64     `json:"delay_ms"`
+	Failures    int       `json:"failures"`          // consecutive failed checks
+	IPv4OK      *bool     `json:"ipv4_ok,omitempty"` // of the last passed check
+	IPv6OK      *bool     `json:"ipv6_ok,omitempty"`
 }
 
-// Get string from cache
-func GetString(key string) string {
-	result, found := Cache.Get(key)
-	if found {
-		return result.(string)
-	}
-	return ""
+// SourceStat is the history of a source, to tell which one feeds garbage
+type SourceStat struct {
+	Kind         string `json:"kind"`
+	Runs         int    `json:"runs"`
+	Fetched      int    `json:"fetched"`  // proxies fetched in all runs
+	Survived     int    `json:"survived"` // proxies passed the checks in all runs
+	LastFetched  int    `json:"last_fetched"`
+	LastSurvived int    `json:"last_survived"`
+	LastError    string `json:"last_error,omitempty"`
+	LastRunTime  string `json:"last_run_time"`
 }
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SourceEntry is the last good response of a source, with the validators for a conditional request
+type SourceEntry struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// SourceStore keeps the last body of each source
+type SourceStore interface {
+	// Load returns os.ErrNotExist when the source has not been saved
+	Load(url string) (*SourceEntry, error)
+	Save(e *SourceEntry) error
+}
+
+// NewSourceStore returns the backend by type name like NewStore: file or none
+func NewSourceStore(kind, dir string) (SourceStore, error) {
+	switch kind {
+	case "file":
+		if dir == "" {
+			return nil, errors.New("source cache: no dir")
+		}
+		return &FileSourceStore{Dir: dir}, nil
+	case "none":
+		return NopSourceStore{}, nil
+	}
+	return nil, fmt.Errorf("source cache: unknown type %s", kind)
+}
+
+// NopSourceStore saves nothing
+type NopSourceStore struct{}
+
+func (NopSourceStore) Load(string) (*SourceEntry, error) {
+	return nil, os.ErrNotExist
+}
+
+func (NopSourceStore) Save(*SourceEntry) error {
+	return nil
+}
+
+// FileSourceStore keeps each source as a json file in Dir, named by the sha1 of the url
+type FileSourceStore struct {
+	Dir string
+}
+
+func (f *FileSourceStore) path(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(f.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (f *FileSourceStore) Load(url string) (*SourceEntry, error) {
+	data, err := os.ReadFile(f.path(url))
+	if err != nil {
+		return nil, err
+	}
+	var e SourceEntry
+	if err = json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("source cache: %s broken: %w", f.path(url), err)
+	}
+	return &e, nil
+}
+
+func (f *FileSourceStore) Save(e *SourceEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path(e.Url), data)
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/reputation"
+)
+
+// Snapshot is the part of the cache which survives a restart
+type Snapshot struct {
+	Proxies      []string                     `json:"proxies"`
+	Reputation   map[string]reputation.Record `json:"reputation"`
+	Overrides    map[string]string            `json:"overrides"`
+	ProxyInfos   map[string]ProxyInfo         `json:"proxy_infos"`
+	SourceStats  map[string]SourceStat        `json:"source_stats"`
+	Checks       map[string]CheckRecord       `json:"checks"`
+	AllowRules   []config.Rule                `json:"allow_rules,omitempty"`
+	DenyRules    []config.Rule                `json:"deny_rules,omitempty"`
+	ClashProxies string                       `json:"clash_proxies"`
+	SurgeProxies string                       `json:"surge_proxies"`
+
+	AllProxiesCount       int    `json:"all_proxies_count"`
+	SSRProxiesCount       int    `json:"ssr_proxies_count"`
+	SSProxiesCount        int    `json:"ss_proxies_count"`
+	VmessProxiesCount     int    `json:"vmess_proxies_count"`
+	TrojanProxiesCount    int    `json:"trojan_proxies_count"`
+	VlessProxiesCount     int    `json:"vless_proxies_count"`
+	Hysteria2ProxiesCount int    `json:"hysteria2_proxies_count"`
+	TuicProxiesCount      int    `json:"tuic_proxies_count"`
+	UsableProxiesCount    int    `json:"usable_proxies_count"`
+	LastCrawlTime         string `json:"last_crawl_time"`
+}
+
+// Store is a persistence backend of the cache
+type Store interface {
+	// Load returns os.ErrNotExist when nothing has been saved yet
+	Load() (*Snapshot, error)
+	Save(s *Snapshot) error
+}
+
+// NewStore returns the backend by type name: file or none
+func NewStore(kind, path string) (Store, error) {
+	switch kind {
+	case "file":
+		if path == "" {
+			return nil, errors.New("cache store: no file path")
+		}
+		return &FileStore{Path: path}, nil
+	case "none":
+		return NopStore{}, nil
+	}
+	return nil, fmt.Errorf("cache store: unknown type %s", kind)
+}
+
+// NopStore saves nothing
+type NopStore struct{}
+
+func (NopStore) Load() (*Snapshot, error) {
+	return nil, os.ErrNotExist
+}
+
+func (NopStore) Save(*Snapshot) error {
+	return nil
+}
+
+// FileStore keeps the snapshot as a json file on local disk
+type FileStore struct {
+	Path string
+}
+
+func (f *FileStore) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("cache store: %s broken: %w", f.Path, err)
+	}
+	return &s, nil
+}
+
+// Save writes to a temp file first, so a crash never leaves a half written snapshot
+func (f *FileStore) Save(s *Snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.Path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// TakeSnapshot collects the current state
+func TakeSnapshot() *Snapshot {
+	state := Current()
+	s := &Snapshot{
+		Proxies:      make([]string, 0, len(state.Proxies)),
+		Reputation:   state.Reputation,
+		Overrides:    state.Overrides,
+		ProxyInfos:   state.ProxyInfos,
+		SourceStats:  state.SourceStats,
+		Checks:       state.Checks,
+		AllowRules:   state.AllowRules,
+		DenyRules:    state.DenyRules,
+		ClashProxies: state.ClashProxies,
+		SurgeProxies: state.SurgeProxies,
+
+		AllProxiesCount:       state.AllProxiesCount,
+		SSRProxiesCount:       state.SSRProxiesCount,
+		SSProxiesCount:        state.SSProxiesCount,
+		VmessProxiesCount:     state.VmessProxiesCount,
+		TrojanProxiesCount:    state.TrojanProxiesCount,
+		VlessProxiesCount:     state.VlessProxiesCount,
+		Hysteria2ProxiesCount: state.Hysteria2ProxiesCount,
+		TuicProxiesCount:      state.TuicProxiesCount,
+		UsableProxiesCount:    state.UsableProxiesCount,
+		LastCrawlTime:         state.LastCrawlTime,
+	}
+	for _, p := range state.Proxies {
+		s.Proxies = append(s.Proxies, p.String())
+	}
+	return s
+}
+
+// RestoreSnapshot publishes a loaded snapshot as the state. decode turns a saved proxy string back to proxy
+func RestoreSnapshot(s *Snapshot, decode func(string) (proxy.Proxy, bool)) {
+	proxies := make(proxy.ProxyList, 0, len(s.Proxies))
+	for _, str := range s.Proxies {
+		if p, ok := decode(str); ok {
+			proxies = append(proxies, p)
+		}
+	}
+	Update(func(state *State) {
+		state.Proxies = proxies
+		if s.Reputation != nil {
+			state.Reputation = s.Reputation
+		}
+		if s.Overrides != nil {
+			state.Overrides = s.Overrides
+		}
+		if s.ProxyInfos != nil {
+			state.ProxyInfos = s.ProxyInfos
+		}
+		if s.SourceStats != nil {
+			state.SourceStats = s.SourceStats
+		}
+		if s.Checks != nil {
+			state.Checks = s.Checks
+		}
+		state.AllowRules = s.AllowRules
+		state.DenyRules = s.DenyRules
+		state.ClashProxies = s.ClashProxies
+		state.SurgeProxies = s.SurgeProxies
+
+		state.AllProxiesCount = s.AllProxiesCount
+		state.SSRProxiesCount = s.SSRProxiesCount
+		state.SSProxiesCount = s.SSProxiesCount
+		state.VmessProxiesCount = s.VmessProxiesCount
+		state.TrojanProxiesCount = s.TrojanProxiesCount
+		state.VlessProxiesCount = s.VlessProxiesCount
+		state.Hysteria2ProxiesCount = s.Hysteria2ProxiesCount
+		state.TuicProxiesCount = s.TuicProxiesCount
+		state.UsableProxiesCount = s.UsableProxiesCount
+		if s.LastCrawlTime != "" {
+			state.LastCrawlTime = s.LastCrawlTime
+		}
+	})
+}
@@ -1,28 +1,109 @@
 package cron
 
 import (
+	"context"
+	"errors"
 	"github.com/qiuchao/proxypoolCheck/config"
 	"github.com/qiuchao/proxypoolCheck/internal/app"
-	"github.com/jasonlvhit/gocron"
+	"github.com/robfig/cron/v3"
 	"log"
 	"runtime"
+	"sync"
 	"time"
 )
 
+var (
+	scheduler     *cron.Cron
+	fullRun       cron.EntryID
+	scheduleMutex sync.Mutex
+	stopped       bool
+)
+
 func Cron() {
-	_ = gocron.Every(config.Config.CronInterval).Minutes().Do(appTask)
-	<-gocron.Start()
+	config.OnReload(reschedule)
+	schedule(*config.Get())
+}
+
+// schedule stops the running scheduler and starts a new one with the schedules of c.
+// A running job is not stopped, it goes on to the end
+func schedule(c config.ConfigOptions) {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	if stopped {
+		return
+	}
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+	s := cron.New(cron.WithLocation(c.Location()))
+	id, err := s.AddFunc(c.FullRunSpec(), appTask)
+	if err != nil {
+		log.Printf("[Andy] Bad schedule %s: %s", c.FullRunSpec(), err)
+	}
+	fullRun = id
+	log.Printf("[Andy] The program will run at: %s\n", c.FullRunSpec())
+	addStage(s, "healthcheck", c.HealthcheckCron, app.HealthcheckCached)
+	addStage(s, "speedtest", c.SpeedtestCron, app.SpeedtestCached)
+	scheduler = s
+	s.Start()
+}
+
+func addStage(s *cron.Cron, name string, spec string, run func() error) {
+	if spec == "" {
+		return
+	}
+	_, err := s.AddFunc(spec, func() {
+		if err := run(); err != nil {
+			log.Printf("[Andy] %s of the cached proxies error: %s\n", name, err.Error())
+		}
+	})
+	if err != nil {
+		log.Printf("[Andy] Bad %s schedule %s: %s", name, spec, err)
+		return
+	}
+	log.Printf("[Andy] The cached proxies will be checked by %s at: %s\n", name, spec)
+}
+
+// Stop stops the scheduler for shutdown, a reload does not start it again. The running jobs are canceled by app.Shutdown
+func Stop() {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	stopped = true
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+}
+
+func reschedule(old, new config.ConfigOptions) {
+	if old.FullRunSpec() != new.FullRunSpec() || old.HealthcheckCron != new.HealthcheckCron ||
+		old.SpeedtestCron != new.SpeedtestCron || old.Timezone != new.Timezone {
+		schedule(new)
+	}
+}
+
+// NextRun is the time of the next full run out of the sleep windows, in the configured timezone. Zero before the cron starts
+func NextRun() time.Time {
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	if scheduler == nil {
+		return time.Time{}
+	}
+	e := scheduler.Entry(fullRun)
+	if !e.Valid() || e.Next.IsZero() {
+		return time.Time{}
+	}
+	return config.Get().NextAwake(e.Schedule, e.Next)
 }
 
 func appTask() {
-	err := config.Parse("")
-	if err != nil{
-		log.Printf("config parse error: %s\n", err.Error())
+	err := config.Reload()
+	if err != nil {
+		log.Printf("config reload error, keep the old config: %s\n", err.Error())
 	}
 	err = app.InitApp()
-	if err != nil { // for wake up heroku
+	if err != nil && !errors.Is(err, context.Canceled) { // for wake up heroku
 		log.Printf("init app err: %s\n Try in 2 minute\n", err.Error())
-		time.Sleep(time.Minute*2)
+		time.Sleep(time.Minute * 2)
 		err = app.InitApp()
 		if err != nil {
 			log.Printf("crawl error: %s\n", err.Error())
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/qiuchao/proxypoolCheck/config"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+const namespace = "proxypoolcheck"
+
+var (
+	sourceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "source_up",
+		Help:      "Whether the last fetch of the source succeeded (1) or not (0).",
+	}, []string{"kind", "url"})
+	sourceProxies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "source_proxies",
+		Help:      "Proxies got from the source in the last fetch.",
+	}, []string{"kind", "url"})
+
+	runDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of a whole check run.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"success"})
+	stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Duration of each stage of a check run.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"stage"})
+	stageProxies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stage_proxies",
+		Help:      "Proxies in and out of each stage of the last run.",
+	}, []string{"stage", "direction"})
+	lastRunTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time the last run finished.",
+	}, []string{"success"})
+
+	reputationProxies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reputation_proxies",
+		Help:      "Proxies with a reputation record by state (good, probation, quarantine) after the last check.",
+	}, []string{"state"})
+
+	proxyBandwidth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "proxy_bandwidth_bytes_per_second",
+		Help:      "Download bandwidth of the proxy in the last third part speed test, -1 for unknown.",
+	}, []string{"name", "type", "server"})
+	proxyTTFB = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "proxy_ttfb_seconds",
+		Help:      "Time to first byte of the proxy in the last third part speed test, -1 for unknown.",
+	}, []string{"name", "type", "server"})
+)
+
+func init() {
+	prometheus.MustRegister(cacheCollector{})
+}
+
+// Handler serves the metrics for prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ResetSources forgets the sources of the last run, so removed sources are not exported any more
+func ResetSources() {
+	sourceUp.Reset()
+	sourceProxies.Reset()
+}
+
+// SetSource exports the last fetch of a source, labeled with the masked url so the token is not scraped
+func SetSource(kind string, url string, ok bool, proxies int) {
+	up := 0.0
+	if ok {
+		up = 1
+	}
+	url = config.MaskURL(url)
+	sourceUp.WithLabelValues(kind, url).Set(up)
+	sourceProxies.WithLabelValues(kind, url).Set(float64(proxies))
+}
+
+func ObserveRun(duration time.Duration, success bool) {
+	label := "false"
+	if success {
+		label = "true"
+	}
+	runDuration.WithLabelValues(label).Observe(duration.Seconds())
+	lastRunTimestamp.WithLabelValues(label).SetToCurrentTime()
+}
+
+func ObserveStage(stage string, duration time.Duration, in int, out int) {
+	stageDuration.WithLabelValues(stage).Observe(duration.Seconds())
+	stageProxies.WithLabelValues(stage, "in").Set(float64(in))
+	stageProxies.WithLabelValues(stage, "out").Set(float64(out))
+}
+
+// SetReputation sets the count of proxies in each reputation state
+func SetReputation(counts map[string]int) {
+	reputationProxies.Reset()
+	for state, n := range counts {
+		reputationProxies.WithLabelValues(state).Set(float64(n))
+	}
+}
+
+// ResetProxySpeeds is called before a new speed test
+func ResetProxySpeeds() {
+	proxyBandwidth.Reset()
+	proxyTTFB.Reset()
+}
+
+func SetProxySpeed(name string, proxyType string, server string, bandwidth float64, ttfb time.Duration) {
+	proxyBandwidth.WithLabelValues(name, proxyType, server).Set(bandwidth)
+	seconds := -1.0
+	if ttfb > 0 {
+		seconds = ttfb.Seconds()
+	}
+	proxyTTFB.WithLabelValues(name, proxyType, server).Set(seconds)
+}
+
+var (
+	proxiesDesc = prometheus.NewDesc(namespace+"_proxies",
+		"Proxies of the last successful run. kind=all is unique proxies before healthcheck, kind=usable is the served ones.",
+		[]string{"kind"}, nil)
+	usableByTypeDesc = prometheus.NewDesc(namespace+"_usable_proxies",
+		"Usable proxies of the last successful run by type.",
+		[]string{"type"}, nil)
+)
+
+// cacheCollector reads the counts from the current state on every scrape
+type cacheCollector struct{}
+
+func (cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- proxiesDesc
+	ch <- usableByTypeDesc
+}
+
+func (cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := cache.Current()
+	ch <- prometheus.MustNewConstMetric(proxiesDesc, prometheus.GaugeValue, float64(s.AllProxiesCount), "all")
+	ch <- prometheus.MustNewConstMetric(proxiesDesc, prometheus.GaugeValue, float64(s.UsableProxiesCount), "usable")
+	for proxyType, count := range map[string]int{
+		"ss":        s.SSProxiesCount,
+		"ssr":       s.SSRProxiesCount,
+		"vmess":     s.VmessProxiesCount,
+		"trojan":    s.TrojanProxiesCount,
+		"vless":     s.VlessProxiesCount,
+		"hysteria2": s.Hysteria2ProxiesCount,
+		"tuic":      s.TuicProxiesCount,
+	} {
+		ch <- prometheus.MustNewConstMetric(usableByTypeDesc, prometheus.GaugeValue, float64(count), proxyType)
+	}
+}
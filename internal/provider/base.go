@@ -0,0 +1,265 @@
+package provider
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypoolCheck/internal/cache"
+)
+
+// Provider is ported from proxypool's provider, so proxy types proxypool does not know (vless, hysteria2, tuic) are not dropped
+
+type Provider interface {
+	Provide() string
+}
+
+type Base struct {
+	Proxies    *proxy.ProxyList `yaml:"proxies"`
+	Types      string           `yaml:"type"`
+	Country    string           `yaml:"country"`
+	NotCountry string           `yaml:"not_country"`
+	Speed      string           `yaml:"speed"`
+	Filter     string           `yaml:"filter"`
+	Source     string           `yaml:"source"` // source urls, a proxy matches when its source url contains one of them
+	Family     string           `yaml:"family"` // ipv4 or ipv6, the proxies working on the family
+
+	Infos  map[string]cache.ProxyInfo `yaml:"-"` // infos of the proxies for the source filter, of the current state when nil
+	Speeds map[string]float64         `yaml:"-"` // speed test results for the speed filter, of the current state when nil
+}
+
+// 根据子类的的Provide()传入的信息筛选节点，结果会改变传入的proxylist。
+func (b *Base) preFilter() {
+	proxies := make(proxy.ProxyList, 0)
+
+	if ok := checkErrorProxies(*b.Proxies); !ok {
+		b.Proxies = &proxies
+		return
+	}
+
+	needFilterType := true
+	needFilterCountry := true
+	needFilterNotCountry := true
+	needFilterSpeed := true
+	needFilterFilter := true
+	needFilterSource := b.Source != ""
+	needFilterFamily := b.Family == "ipv4" || b.Family == "ipv6"
+	if b.Types == "" || b.Types == "all" {
+		needFilterType = false
+	}
+	if b.Country == "" || b.Country == "all" {
+		needFilterCountry = false
+	}
+	if b.NotCountry == "" {
+		needFilterNotCountry = false
+	}
+	if b.Filter == "" {
+		needFilterFilter = false
+	}
+	types := strings.Split(b.Types, ",")
+	countries := strings.Split(b.Country, ",")
+	notCountries := strings.Split(b.NotCountry, ",")
+	sources := strings.Split(b.Source, ",")
+	infos := b.Infos
+	if (needFilterSource || needFilterFamily) && infos == nil {
+		infos = cache.Current().ProxyInfos
+	}
+	speedMin, speedMax := checkSpeed(strings.Split(b.Speed, ","))
+
+	if speedMin == -1 {
+		needFilterSpeed = false
+	}
+	speeds := b.Speeds
+	if needFilterSpeed && speeds == nil {
+		speeds = cache.Current().Speeds
+	}
+
+	bProxies := *b.Proxies
+	for _, p := range bProxies {
+		if needFilterType {
+			typeOk := false
+			for _, t := range types {
+				if p.TypeName() == t {
+					typeOk = true
+					break
+				}
+			}
+			if !typeOk {
+				continue
+			}
+		}
+
+		if needFilterNotCountry {
+			excluded := false
+			for _, c := range notCountries {
+				if strings.Contains(p.BaseInfo().Name, c) {
+					excluded = true
+					break
+				}
+			}
+			if excluded {
+				continue
+			}
+		}
+
+		if needFilterCountry {
+			countryOk := false
+			for _, c := range countries {
+				if strings.Contains(p.BaseInfo().Name, c) {
+					countryOk = true
+					break
+				}
+			}
+			if !countryOk {
+				continue
+			}
+		}
+
+		if needFilterFilter && !checkFilter(p.BaseInfo().Name, b.Filter) {
+			continue
+		}
+
+		if needFilterSource && !checkSource(infos[p.Identifier()].Source, sources) {
+			continue
+		}
+
+		if needFilterFamily && !checkFamily(infos[p.Identifier()], b.Family) {
+			continue
+		}
+
+		if needFilterSpeed && len(speeds) != 0 {
+			if speed, ok := speeds[p.Identifier()]; ok {
+				if speed != 0 {
+					// clear history speed tag
+					names := strings.Split(p.BaseInfo().Name, " |")
+					if len(names) > 1 {
+						p.BaseInfo().Name = names[0]
+					}
+					// check speed
+					if speed > speedMin && speed < speedMax {
+						p.AddToName(fmt.Sprintf(" |%5.2fMb", speed))
+					} else {
+						continue
+					}
+				} else if speedMin != 0 { // still show 0 speed proxy when speed Min is 0
+					continue
+				}
+			} else if speedMin != 0 { // still show no speed result proxy when speed Min is 0
+				continue
+			}
+		} else {
+			names := strings.Split(p.BaseInfo().Name, " |")
+			if len(names) > 1 {
+				p.BaseInfo().Name = names[0]
+			}
+		}
+
+		proxies = append(proxies, p)
+	}
+
+	b.Proxies = &proxies
+}
+
+// Filtered returns the proxies left by the same filters as Provide()
+func (b Base) Filtered() proxy.ProxyList {
+	b.preFilter()
+	return *b.Proxies
+}
+
+// useFamily puts the address of the family into the filtered proxies, an ipv6 only client can not dial the ipv4 one.
+// The Identifier() changes, call it after the filters
+func (b *Base) useFamily() {
+	if b.Family != "ipv4" && b.Family != "ipv6" {
+		return
+	}
+	infos := b.Infos
+	if infos == nil {
+		infos = cache.Current().ProxyInfos
+	}
+	for _, p := range *b.Proxies {
+		for _, s := range infos[p.Identifier()].IPs {
+			if ip := net.ParseIP(s); ip != nil && (ip.To4() == nil) == (b.Family == "ipv6") {
+				p.SetIP(s)
+				break
+			}
+		}
+	}
+}
+
+func checkFamily(info cache.ProxyInfo, family string) bool {
+	ok := info.IPv4OK
+	if family == "ipv6" {
+		ok = info.IPv6OK
+	}
+	return ok != nil && *ok
+}
+
+// r为中转，p为pool，rp为中转+pool，nr为非中转，np为非pool，nrp为原生ip
+func checkFilter(name string, filter string) bool {
+	relay := strings.Contains(name, "Relay")
+	pool := strings.Contains(name, "Pool")
+	switch filter {
+	case "r":
+		return relay
+	case "p":
+		return pool
+	case "rp":
+		return relay || pool
+	case "nr":
+		return !relay
+	case "np":
+		return !pool
+	case "nrp":
+		return !relay && !pool
+	}
+	return true
+}
+
+func checkSource(source string, sources []string) bool {
+	if source == "" {
+		return false
+	}
+	for _, s := range sources {
+		if s != "" && strings.Contains(source, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkErrorProxies(proxies []proxy.Proxy) bool {
+	if proxies == nil {
+		return false
+	}
+	if len(proxies) == 0 {
+		return false
+	}
+	if proxies[0] == nil {
+		return false
+	}
+	return true
+}
+
+func checkSpeed(speed []string) (speedMin float64, speedMax float64) {
+	speedMin, speedMax = 0, 1000
+	var err1, err2 error
+	switch len(speed) {
+	case 1:
+		if speed[0] != "" {
+			speedMin, err1 = strconv.ParseFloat(speed[0], 64)
+		}
+	case 2:
+		speedMin, err1 = strconv.ParseFloat(speed[0], 64)
+		speedMax, err2 = strconv.ParseFloat(speed[1], 64)
+	}
+	if math.IsNaN(speedMin) || err1 != nil {
+		speedMin = 0.00
+	}
+	if math.IsNaN(speedMax) || err2 != nil {
+		speedMax = 1000.00
+	}
+	return speedMin, speedMax
+}
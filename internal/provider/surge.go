@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/qiuchao/proxypool/pkg/proxy"
+	"github.com/qiuchao/proxypool/pkg/tool"
+)
+
+// Surge provides functions that make proxies support surge client
+type Surge struct {
+	Base
+}
+
+// Provide of Surge generates proxy list supported by surge
+func (s Surge) Provide() string {
+	s.preFilter()
+	s.useFamily()
+
+	var resultBuilder strings.Builder
+	for _, p := range *s.Proxies {
+		if checkSurgeSupport(p) {
+			resultBuilder.WriteString(p.ToSurge() + "\n")
+		}
+	}
+	return resultBuilder.String()
+}
+
+func checkSurgeSupport(p proxy.Proxy) bool {
+	switch p := p.(type) {
+	case *proxy.Vmess:
+		return true
+	case *proxy.Shadowsocks:
+		if tool.CheckInList(proxy.SSCipherList, p.Cipher) {
+			return true
+		}
+	}
+	return false
+}
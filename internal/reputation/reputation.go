@@ -0,0 +1,131 @@
+package reputation
+
+import (
+	"math"
+	"time"
+)
+
+// States of a node
+const (
+	Good       = "good"
+	Quarantine = "quarantine" // not tested nor served until the quarantine ends
+	Probation  = "probation"  // tested again after a quarantine, a failure puts it back for twice as long
+)
+
+// Overrides set by hand, by Identifier()
+const (
+	Pin = "pin" // never quarantined
+	Ban = "ban" // never tested nor served
+)
+
+// Policy is how the checks move a node between the states
+type Policy struct {
+	Alpha              float64       // weight of the newest check in the averages, 0-1
+	QuarantineFailures int           // consecutive failures to quarantine a good node
+	QuarantineBelow    float64       // success rate to quarantine a good node on a failure, after MinChecks checks
+	MinChecks          int           // checks before the success rate counts
+	Quarantine         time.Duration // the first quarantine, doubled by every failed probation up to MaxQuarantine
+	MaxQuarantine      time.Duration
+	ProbationPasses    int           // passes in a row for a node on probation to be good again
+	Forget             time.Duration // records not checked for this long are dropped
+}
+
+// Record is the check history of a node
+type Record struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Server string `json:"server"`
+
+	State           string    `json:"state"`
+	SuccessRate     float64   `json:"success_rate"` // EWMA of the checks, 1 for pass and 0 for failure
+	LatencyMs       float64   `json:"latency_ms"`   // EWMA of the delays of the passed checks
+	Checks          int       `json:"checks"`
+	Failures        int       `json:"failures"`    // in a row
+	Passes          int       `json:"passes"`      // in a row
+	Quarantines     int       `json:"quarantines"` // in a row without being good between, the quarantine doubles
+	QuarantineUntil time.Time `json:"quarantine_until"`
+	LastChecked     time.Time `json:"last_checked"`
+}
+
+// Skip tells whether the node is in quarantine at now, it is not tested then
+func (r Record) Skip(now time.Time) bool {
+	return r.State == Quarantine && now.Before(r.QuarantineUntil)
+}
+
+// Score is 0-100, the success rate lowered by the latency: no loss up to 300ms, half at 3s or more
+func (r Record) Score() int {
+	factor := 1.0
+	if r.LatencyMs > 300 {
+		factor = 1 - 0.5*math.Min((r.LatencyMs-300)/2700, 1)
+	}
+	return int(math.Round(r.SuccessRate * factor * 100))
+}
+
+// Observe adds a check result. A pinned node is never quarantined
+func (r *Record) Observe(ok bool, latency time.Duration, now time.Time, p Policy, pinned bool) {
+	x := 0.0
+	if ok {
+		x = 1
+	}
+	if r.Checks == 0 {
+		r.SuccessRate = x
+	} else {
+		r.SuccessRate = p.Alpha*x + (1-p.Alpha)*r.SuccessRate
+	}
+	if ok {
+		ms := float64(latency.Milliseconds())
+		if r.LatencyMs == 0 {
+			r.LatencyMs = ms
+		} else {
+			r.LatencyMs = p.Alpha*ms + (1-p.Alpha)*r.LatencyMs
+		}
+		r.Failures = 0
+		r.Passes++
+	} else {
+		r.Failures++
+		r.Passes = 0
+	}
+	r.Checks++
+	r.LastChecked = now
+
+	if pinned {
+		r.State = Good
+		r.Quarantines = 0
+		return
+	}
+	switch r.State {
+	case Quarantine, Probation:
+		// a quarantined one is tested when its quarantine ends
+		r.State = Probation
+		if !ok {
+			r.quarantine(now, p)
+		} else if r.Passes >= p.ProbationPasses {
+			r.State = Good
+			r.Quarantines = 0
+		}
+	default:
+		r.State = Good
+		if !ok && (r.Failures >= p.QuarantineFailures || (r.Checks >= p.MinChecks && r.SuccessRate < p.QuarantineBelow)) {
+			r.quarantine(now, p)
+		}
+	}
+}
+
+func (r *Record) quarantine(now time.Time, p Policy) {
+	d := p.Quarantine
+	for i := 0; i < r.Quarantines && d < p.MaxQuarantine; i++ {
+		d *= 2
+	}
+	if d > p.MaxQuarantine {
+		d = p.MaxQuarantine
+	}
+	r.Quarantines++
+	r.State = Quarantine
+	r.QuarantineUntil = now.Add(d)
+	r.Passes = 0
+}
+
+// Forgotten tells whether the record is too old to keep
+func (r Record) Forgotten(now time.Time, p Policy) bool {
+	return p.Forget > 0 && now.Sub(r.LastChecked) > p.Forget
+}
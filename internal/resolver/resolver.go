@@ -0,0 +1,241 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Address families to use, the preferred one first
+const (
+	PreferIPv4 = "ipv4"
+	PreferIPv6 = "ipv6"
+	IPv4Only   = "ipv4_only"
+	IPv6Only   = "ipv6_only"
+)
+
+const (
+	minTTL      = time.Minute // answers with a shorter ttl are kept this long
+	negativeTTL = time.Minute // failures are kept this long
+)
+
+// Options of a Resolver
+type Options struct {
+	Servers     []string      // upstreams tried in order, see parseServer. Empty for the system resolver
+	Concurrency int           // hosts resolved at a time by ResolveAll
+	Timeout     time.Duration // of one query
+	CacheTTL    time.Duration // the longest an answer is kept, the record ttl wins when shorter. 0 for no cache
+	Prefer      string
+}
+
+// Result is the lookup of a host
+type Result struct {
+	IPs    []net.IP // the preferred family first
+	Err    error
+	Cached bool
+}
+
+type entry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+// Resolver looks up the servers of the proxies with a cache
+type Resolver struct {
+	opts      Options
+	upstreams []upstream
+	now       func() time.Time // the clock of the cache
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+func New(o Options) (*Resolver, error) {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	r := &Resolver{opts: o, now: time.Now, cache: make(map[string]entry)}
+	servers := o.Servers
+	if len(servers) == 0 {
+		servers = []string{"system"}
+	}
+	for _, s := range servers {
+		u, err := parseServer(s, o.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		r.upstreams = append(r.upstreams, u)
+	}
+	return r, nil
+}
+
+// Options are the options r was made with
+func (r *Resolver) Options() Options {
+	return r.opts
+}
+
+// ResolveAll looks up the hosts, up to Concurrency at a time. When ctx is done the hosts not looked up yet
+// have ctx.Err()
+func (r *Resolver) ResolveAll(ctx context.Context, hosts []string) map[string]Result {
+	r.purge(r.now())
+	results := make(map[string]Result, len(hosts))
+	var m sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(r.opts.Concurrency, len(hosts)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				res := r.lookup(ctx, host)
+				m.Lock()
+				results[host] = res
+				m.Unlock()
+			}
+		}()
+	}
+	seen := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		if ctx.Err() != nil {
+			m.Lock()
+			results[host] = Result{Err: ctx.Err()}
+			m.Unlock()
+			continue
+		}
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// LookupIP looks up one host, an ip is returned as it is
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	res := r.lookup(ctx, host)
+	return res.IPs, res.Err
+}
+
+func (r *Resolver) lookup(ctx context.Context, host string) Result {
+	if ip := net.ParseIP(host); ip != nil {
+		return Result{IPs: []net.IP{ip}}
+	}
+	now := r.now()
+	r.mu.Lock()
+	e, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return Result{IPs: e.ips, Err: e.err, Cached: true}
+	}
+
+	ips, ttl, err := r.resolve(ctx, host)
+	if ctx.Err() != nil {
+		return Result{Err: ctx.Err()} // not an answer, not cached
+	}
+	if err != nil {
+		ttl = negativeTTL
+	} else if ttl < 0 {
+		ttl = r.opts.CacheTTL // the system resolver does not tell
+	}
+	ttl = min(max(ttl, minTTL), r.opts.CacheTTL)
+	if ttl > 0 {
+		r.mu.Lock()
+		r.cache[host] = entry{ips: ips, err: err, expires: now.Add(ttl)}
+		r.mu.Unlock()
+	}
+	return Result{IPs: ips, Err: err}
+}
+
+// resolve asks the families of Prefer at the same time, a host is good when one of them has an address
+func (r *Resolver) resolve(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	var qtypes []uint16
+	switch r.opts.Prefer {
+	case IPv4Only:
+		qtypes = []uint16{dns.TypeA}
+	case IPv6Only:
+		qtypes = []uint16{dns.TypeAAAA}
+	case PreferIPv6:
+		qtypes = []uint16{dns.TypeAAAA, dns.TypeA}
+	default:
+		qtypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	}
+	type answer struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	answers := make([]answer, len(qtypes))
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+			ips, ttl, err := r.query(ctx, host, qtype)
+			answers[i] = answer{ips, ttl, err}
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	var ips []net.IP
+	ttl := time.Duration(-1)
+	var err error
+	for _, a := range answers {
+		if a.err != nil {
+			if err == nil || errors.Is(err, errNotFound) {
+				err = a.err // a network error tells more than no such host
+			}
+			continue
+		}
+		ips = append(ips, a.ips...)
+		if a.ttl >= 0 && (ttl < 0 || a.ttl < ttl) {
+			ttl = a.ttl
+		}
+	}
+	if len(ips) > 0 {
+		return ips, ttl, nil
+	}
+	if err == nil {
+		err = errors.New("no address")
+		if len(qtypes) == 1 {
+			err = fmt.Errorf("no %s address", r.opts.Prefer[:4])
+		}
+	}
+	return nil, 0, fmt.Errorf("lookup %s: %w", host, err)
+}
+
+// query asks the upstreams in order until one answers, no such host is an answer
+func (r *Resolver) query(ctx context.Context, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	var errs []error
+	for _, u := range r.upstreams {
+		qctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+		ips, ttl, err := u.lookup(qctx, host, qtype)
+		cancel()
+		if err == nil || errors.Is(err, errNotFound) || ctx.Err() != nil {
+			return ips, ttl, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", u, err))
+	}
+	return nil, 0, errors.Join(errs...)
+}
+
+func (r *Resolver) purge(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for host, e := range r.cache {
+		if !now.Before(e.expires) {
+			delete(r.cache, host)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type fakeAnswer struct {
+	ips []string
+	ttl time.Duration
+	err error
+}
+
+// fakeUpstream answers by host and query type, a host it does not know is not found
+type fakeUpstream struct {
+	name    string
+	answers map[string]map[uint16]fakeAnswer
+
+	mu    sync.Mutex
+	calls map[uint16]int
+}
+
+func (f *fakeUpstream) lookup(_ context.Context, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[uint16]int)
+	}
+	f.calls[qtype]++
+	f.mu.Unlock()
+	byType, ok := f.answers[host]
+	if !ok {
+		return nil, 0, errNotFound
+	}
+	a := byType[qtype]
+	var ips []net.IP
+	for _, s := range a.ips {
+		ips = append(ips, net.ParseIP(s))
+	}
+	return ips, a.ttl, a.err
+}
+
+func (f *fakeUpstream) String() string {
+	if f.name == "" {
+		return "fake"
+	}
+	return f.name
+}
+
+func (f *fakeUpstream) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		n += c
+	}
+	return n
+}
+
+// clock is a fake time for the cache
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestResolver(t *testing.T, o Options, upstreams ...upstream) (*Resolver, *clock) {
+	r, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r.now = c.now
+	r.upstreams = upstreams
+	return r, c
+}
+
+func dualStack(ttl time.Duration) map[string]map[uint16]fakeAnswer {
+	return map[string]map[uint16]fakeAnswer{
+		"dual.test": {
+			dns.TypeA:    {ips: []string{"192.0.2.1"}, ttl: ttl},
+			dns.TypeAAAA: {ips: []string{"2001:db8::1"}, ttl: ttl},
+		},
+	}
+}
+
+func TestLookupCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		cacheTTL time.Duration
+		ttl      time.Duration // of the records, -1 for not told
+		cached   time.Duration // still cached just before it
+	}{
+		{"the record ttl", 10 * time.Minute, 5 * time.Minute, 5 * time.Minute},
+		{"a short ttl is kept minTTL", 10 * time.Minute, 5 * time.Second, minTTL},
+		{"cache_ttl caps a long ttl", 10 * time.Minute, time.Hour, 10 * time.Minute},
+		{"cache_ttl when the ttl is not told", 10 * time.Minute, -1, 10 * time.Minute},
+		{"cache_ttl below minTTL wins", 30 * time.Second, 5 * time.Minute, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeUpstream{answers: dualStack(tt.ttl)}
+			r, c := newTestResolver(t, Options{CacheTTL: tt.cacheTTL}, f)
+			ctx := context.Background()
+			if res := r.lookup(ctx, "dual.test"); res.Err != nil || res.Cached || len(res.IPs) != 2 {
+				t.Fatalf("first lookup = %+v", res)
+			}
+			c.advance(tt.cached - time.Second)
+			if res := r.lookup(ctx, "dual.test"); !res.Cached || len(res.IPs) != 2 {
+				t.Fatalf("lookup after %s = %+v, want cached", tt.cached-time.Second, res)
+			}
+			c.advance(time.Second)
+			if res := r.lookup(ctx, "dual.test"); res.Cached {
+				t.Fatalf("lookup after %s = %+v, want a new query", tt.cached, res)
+			}
+			if n := f.count(); n != 4 {
+				t.Fatalf("upstream asked %d times, want 4", n)
+			}
+		})
+	}
+}
+
+func TestLookupNegativeCache(t *testing.T) {
+	f := &fakeUpstream{answers: dualStack(time.Hour)}
+	r, c := newTestResolver(t, Options{CacheTTL: 10 * time.Minute}, f)
+	ctx := context.Background()
+	res := r.lookup(ctx, "missing.test")
+	if !errors.Is(res.Err, errNotFound) {
+		t.Fatalf("lookup of a missing host = %+v, want no such host", res)
+	}
+	c.advance(negativeTTL - time.Second)
+	if res = r.lookup(ctx, "missing.test"); !res.Cached || !errors.Is(res.Err, errNotFound) {
+		t.Fatalf("lookup within negativeTTL = %+v, want the cached failure", res)
+	}
+	c.advance(time.Second)
+	if res = r.lookup(ctx, "missing.test"); res.Cached {
+		t.Fatalf("lookup after negativeTTL = %+v, want a new query", res)
+	}
+}
+
+func TestLookupNoCache(t *testing.T) {
+	f := &fakeUpstream{answers: dualStack(time.Hour)}
+	r, _ := newTestResolver(t, Options{}, f)
+	for i := 0; i < 3; i++ {
+		if res := r.lookup(context.Background(), "dual.test"); res.Cached {
+			t.Fatalf("lookup %d is cached without a cache", i)
+		}
+	}
+	if n := f.count(); n != 6 {
+		t.Fatalf("upstream asked %d times, want 6", n)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	f := &fakeUpstream{answers: dualStack(time.Hour)}
+	r, c := newTestResolver(t, Options{CacheTTL: 10 * time.Minute}, f)
+	r.lookup(context.Background(), "dual.test")
+	r.lookup(context.Background(), "missing.test")
+	c.advance(negativeTTL)
+	r.purge(c.now())
+	if _, ok := r.cache["missing.test"]; ok {
+		t.Fatal("an expired failure is kept")
+	}
+	if _, ok := r.cache["dual.test"]; !ok {
+		t.Fatal("a fresh answer is purged")
+	}
+}
+
+func TestResolveFamilies(t *testing.T) {
+	answers := dualStack(time.Minute)
+	answers["v4.test"] = map[uint16]fakeAnswer{dns.TypeA: {ips: []string{"192.0.2.2"}}}
+	answers["broken6.test"] = map[uint16]fakeAnswer{
+		dns.TypeA:    {ips: []string{"192.0.2.3"}},
+		dns.TypeAAAA: {err: errors.New("timeout")},
+	}
+	tests := []struct {
+		prefer  string
+		host    string
+		want    string // the ips, or the error
+		queries int
+	}{
+		{PreferIPv4, "dual.test", "192.0.2.1 2001:db8::1", 2},
+		{"", "dual.test", "192.0.2.1 2001:db8::1", 2},
+		{PreferIPv6, "dual.test", "2001:db8::1 192.0.2.1", 2},
+		{IPv4Only, "dual.test", "192.0.2.1", 1},
+		{IPv6Only, "dual.test", "2001:db8::1", 1},
+		{PreferIPv6, "v4.test", "192.0.2.2", 2},
+		{IPv6Only, "v4.test", "lookup v4.test: no ipv6 address", 1},
+		{PreferIPv4, "broken6.test", "192.0.2.3", 2},
+		{IPv6Only, "broken6.test", "lookup broken6.test: fake: timeout", 1},
+		{PreferIPv4, "missing.test", "lookup missing.test: no such host", 2},
+		{PreferIPv4, "192.0.2.9", "192.0.2.9", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefer+" "+tt.host, func(t *testing.T) {
+			f := &fakeUpstream{answers: answers}
+			r, _ := newTestResolver(t, Options{Prefer: tt.prefer}, f)
+			ips, err := r.LookupIP(context.Background(), tt.host)
+			got := fmt.Sprint(err)
+			if err == nil {
+				got = strings.Trim(fmt.Sprint(ips), "[]")
+			}
+			if got != tt.want {
+				t.Fatalf("LookupIP() = %s, want %s", got, tt.want)
+			}
+			if n := f.count(); n != tt.queries {
+				t.Fatalf("upstream asked %d times, want %d", n, tt.queries)
+			}
+		})
+	}
+}
+
+func TestQueryUpstreamsInOrder(t *testing.T) {
+	down := &fakeUpstream{name: "down", answers: map[string]map[uint16]fakeAnswer{
+		"dual.test": {dns.TypeA: {err: errors.New("refused")}, dns.TypeAAAA: {err: errors.New("refused")}},
+	}}
+	up := &fakeUpstream{name: "up", answers: dualStack(time.Minute)}
+	r, _ := newTestResolver(t, Options{}, down, up)
+	ips, err := r.LookupIP(context.Background(), "dual.test")
+	if err != nil || len(ips) != 2 {
+		t.Fatalf("LookupIP() = %v, %v, want the answer of the second upstream", ips, err)
+	}
+
+	// no such host is an answer, the next upstream is not asked
+	other := &fakeUpstream{name: "other", answers: dualStack(time.Minute)}
+	r, _ = newTestResolver(t, Options{}, up, other)
+	if _, err = r.LookupIP(context.Background(), "missing.test"); !errors.Is(err, errNotFound) {
+		t.Fatalf("LookupIP() error = %v, want no such host", err)
+	}
+	if n := other.count(); n != 0 {
+		t.Fatalf("the next upstream is asked %d times after no such host", n)
+	}
+
+	// all failed, the errors of all the upstreams
+	r, _ = newTestResolver(t, Options{}, down, down)
+	if _, err = r.LookupIP(context.Background(), "dual.test"); err == nil || strings.Count(err.Error(), "down: refused") != 2 {
+		t.Fatalf("LookupIP() error = %v, want the errors of both upstreams", err)
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	f := &fakeUpstream{answers: dualStack(time.Minute)}
+	r, _ := newTestResolver(t, Options{Concurrency: 4, CacheTTL: time.Minute}, f)
+	results := r.ResolveAll(context.Background(), []string{"dual.test", "dual.test", "missing.test", "192.0.2.9"})
+	if len(results) != 3 || len(results["dual.test"].IPs) != 2 || results["missing.test"].Err == nil || results["192.0.2.9"].Err != nil {
+		t.Fatalf("ResolveAll() = %+v", results)
+	}
+	if n := f.count(); n != 4 {
+		t.Fatalf("upstream asked %d times, want 4 for the two hosts", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = r.ResolveAll(ctx, []string{"other.test"})
+	if !errors.Is(results["other.test"].Err, context.Canceled) {
+		t.Fatalf("ResolveAll() after cancel = %+v", results)
+	}
+	if _, ok := r.cache["other.test"]; ok {
+		t.Fatal("a canceled lookup is cached")
+	}
+}
+
+// TestDNSUpstream asks a dns server on localhost
+func TestDNSUpstream(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		q := req.Question[0]
+		switch {
+		case q.Name != "dual.test.":
+			m.Rcode = dns.RcodeNameError
+		case q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer,
+				&dns.CNAME{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 30}, Target: "cdn.test."},
+				&dns.A{Hdr: dns.RR_Header{Name: "cdn.test.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP("192.0.2.1")},
+				&dns.A{Hdr: dns.RR_Header{Name: "cdn.test.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 120}, A: net.ParseIP("192.0.2.2")})
+		case q.Qtype == dns.TypeAAAA:
+			m.Answer = append(m.Answer,
+				&dns.AAAA{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 600}, AAAA: net.ParseIP("2001:db8::1")})
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	u, err := parseServer(pc.LocalAddr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ips, ttl, err := u.lookup(context.Background(), "dual.test", dns.TypeA)
+	if err != nil || fmt.Sprint(ips) != "[192.0.2.1 192.0.2.2]" || ttl != 120*time.Second {
+		t.Fatalf("lookup A = %v, %s, %v, want the two addresses with the lowest ttl", ips, ttl, err)
+	}
+	ips, ttl, err = u.lookup(context.Background(), "dual.test", dns.TypeAAAA)
+	if err != nil || fmt.Sprint(ips) != "[2001:db8::1]" || ttl != 600*time.Second {
+		t.Fatalf("lookup AAAA = %v, %s, %v", ips, ttl, err)
+	}
+	if _, _, err = u.lookup(context.Background(), "missing.test", dns.TypeA); !errors.Is(err, errNotFound) {
+		t.Fatalf("lookup of a missing host error = %v, want no such host", err)
+	}
+}
+
+func TestCheckServer(t *testing.T) {
+	for _, s := range []string{"system", "1.1.1.1", "1.1.1.1:5353", "udp://1.1.1.1", "tcp://[2606:4700::1111]:53", "tls://dns.google", "https://dns.google/dns-query"} {
+		if err := CheckServer(s); err != nil {
+			t.Errorf("CheckServer(%q) = %v", s, err)
+		}
+	}
+	for _, s := range []string{"ftp://1.1.1.1", "udp://", "https://"} {
+		if err := CheckServer(s); err == nil {
+			t.Errorf("CheckServer(%q) passed", s)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// errNotFound is a final answer, the other upstreams are not asked
+var errNotFound = errors.New("no such host")
+
+// upstream is a dns server. ttl is -1 when the server does not tell it
+type upstream interface {
+	lookup(ctx context.Context, host string, qtype uint16) (ips []net.IP, ttl time.Duration, err error)
+	String() string
+}
+
+// CheckServer tells whether s is a valid upstream for Options.Servers
+func CheckServer(s string) error {
+	_, err := parseServer(s, time.Second)
+	return err
+}
+
+// parseServer takes system, 1.1.1.1, udp://1.1.1.1:53, tcp://1.1.1.1, tls://dns.google:853 or https://dns.google/dns-query
+func parseServer(s string, timeout time.Duration) (upstream, error) {
+	if s == "system" {
+		return systemUpstream{}, nil
+	}
+	if !strings.Contains(s, "://") {
+		s = "udp://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("bad dns server %q", s)
+	}
+	switch u.Scheme {
+	case "https":
+		return dohUpstream{url: u.String(), client: &http.Client{Timeout: timeout}}, nil
+	case "udp", "tcp", "tls":
+		port := "53"
+		if u.Scheme == "tls" {
+			port = "853"
+		}
+		if u.Port() != "" {
+			port = u.Port()
+		}
+		c := &dns.Client{Net: u.Scheme, Timeout: timeout}
+		if u.Scheme == "tls" {
+			c.Net = "tcp-tls"
+			c.TLSConfig = &tls.Config{ServerName: u.Hostname()}
+		}
+		return dnsUpstream{name: s, addr: net.JoinHostPort(u.Hostname(), port), client: c}, nil
+	}
+	return nil, fmt.Errorf("bad dns server %q, the scheme must be udp, tcp, tls or https", s)
+}
+
+// systemUpstream is the resolver of the os
+type systemUpstream struct{}
+
+func (systemUpstream) lookup(ctx context.Context, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	network := "ip4"
+	if qtype == dns.TypeAAAA {
+		network = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, -1, errNotFound
+	}
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) {
+		return nil, -1, nil // no address of this family
+	}
+	return ips, -1, err
+}
+
+func (systemUpstream) String() string {
+	return "system"
+}
+
+// dnsUpstream is a plain dns server over udp or tcp, or dns over tls
+type dnsUpstream struct {
+	name   string
+	addr   string
+	client *dns.Client
+}
+
+func (u dnsUpstream) lookup(ctx context.Context, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(host), qtype)
+	r, _, err := u.client.ExchangeContext(ctx, m, u.addr)
+	if err == nil && r.Truncated && u.client.Net == "udp" {
+		// too long for udp, ask again over tcp
+		tcp := *u.client
+		tcp.Net = "tcp"
+		r, _, err = tcp.ExchangeContext(ctx, m, u.addr)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return answer(r, qtype)
+}
+
+func (u dnsUpstream) String() string {
+	return u.name
+}
+
+// dohUpstream is dns over https, RFC 8484
+type dohUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func (u dohUpstream) lookup(ctx context.Context, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(host), qtype)
+	m.Id = 0 // recommended by the rfc for the http caches
+	body, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%s: http %d", u.url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, 0, err
+	}
+	r := new(dns.Msg)
+	if err = r.Unpack(data); err != nil {
+		return nil, 0, err
+	}
+	return answer(r, qtype)
+}
+
+func (u dohUpstream) String() string {
+	return u.url
+}
+
+// answer is the addresses of a reply with the lowest ttl of them
+func answer(r *dns.Msg, qtype uint16) ([]net.IP, time.Duration, error) {
+	switch r.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, 0, errNotFound
+	default:
+		return nil, 0, fmt.Errorf("server answered %s", dns.RcodeToString[r.Rcode])
+	}
+	var ips []net.IP
+	ttl := time.Duration(-1)
+	for _, rr := range r.Answer {
+		var ip net.IP
+		switch a := rr.(type) {
+		case *dns.A:
+			ip = a.A
+		case *dns.AAAA:
+			ip = a.AAAA
+		default:
+			continue // CNAME, the addresses follow it
+		}
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		ips = append(ips, ip)
+		if d := time.Duration(rr.Header().Ttl) * time.Second; ttl < 0 || d < ttl {
+			ttl = d
+		}
+	}
+	return ips, ttl, nil
+}
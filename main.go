@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"github.com/qiuchao/proxypoolCheck/api"
 	"github.com/qiuchao/proxypoolCheck/config"
 	"github.com/qiuchao/proxypoolCheck/internal/app"
 	"github.com/qiuchao/proxypoolCheck/internal/cron"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 var configFilePath = ""
+var checkConfig = false
 
-func main()  {
+func main() {
 	go func() {
 		http.ListenAndServe("0.0.0.0:6061", nil)
 	}()
@@ -21,22 +28,77 @@ func main()  {
 
 	// fetch configuration
 	flag.StringVar(&configFilePath, "c", "", "path to config file: config.yaml")
+	flag.BoolVar(&checkConfig, "check-config", false, "validate the config file and exit, non-zero when it is bad")
+	config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 	if configFilePath == "" {
 		configFilePath = "config.yaml"
 	}
 	err := config.Parse(configFilePath)
+	if checkConfig {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: config ok\n", configFilePath)
+		return
+	}
 	log.Printf("[Andy] Main config file: %s", configFilePath)
 	if err != nil {
 		log.Fatal(err, "\n\"Config file err. Exit\"")
 		return
 	}
+	log.Printf("[Andy] Effective config (flags > env > file > defaults):\n%s", config.Effective())
+
+	if err = app.LoadCache(); err != nil {
+		log.Printf("[Andy] Load cache error: %s", err)
+	}
 
 	go app.InitApp()
-	log.Printf("[Andy] The program will run every %v minutes\n", config.Config.CronInterval)
 	go cron.Cron()
+	go app.WatchLocal()
+	go reloadOnSignal()
+	go shutdownOnSignal()
 	// Run
 	api.Run()
 
+}
 
+// ctrl-c or kill stops the scheduler and the run going on, saves what is checked and stops the web server.
+// A second signal exits at once
+func shutdownOnSignal() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	sig := <-c
+	log.Printf("[Andy] Got %s, shutting down", sig)
+	go func() {
+		<-c
+		log.Println("[Andy] Exit without waiting")
+		os.Exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cron.Stop()
+	if err := app.Shutdown(ctx); err != nil {
+		log.Printf("[Andy] Save cache on shutdown error: %s", err)
+	}
+	if err := api.Shutdown(ctx); err != nil {
+		log.Printf("[Andy] Web server shutdown error: %s", err)
+	}
+	log.Println("[Andy] Bye")
+	os.Exit(0)
+}
+
+// kill -HUP reloads the config file, the old config is kept when the new one is bad
+func reloadOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if err := config.Reload(); err != nil {
+			log.Printf("[Andy] Reload config error, keep the old config: %s", err)
+			continue
+		}
+		log.Printf("[Andy] Config reloaded: %s", configFilePath)
+	}
 }